@@ -0,0 +1,31 @@
+package cmd
+
+import (
+	"github.com/spf13/cobra"
+)
+
+var configCmd = &cobra.Command{
+	Use:   "config",
+	Short: "Inspect the configuration",
+	Long: `Inspect the configuration that ghm uses. The repository's .githooksrc.yml
+is merged with these layers, from lowest to highest precedence:
+
+  /etc/githookd/config.yml                  system
+  $XDG_CONFIG_HOME/githookd/config.yml      user (default ~/.config)
+  .githooksrc.yml                           repository
+  .githooksrc.local.yml                     local, not committed
+
+A higher layer's top-level values replace the lower ones. Its commands
+update the command with the same id, or are appended. The top-level merge
+map changes this per hook: append adds every command, and replace discards
+the lower layers' commands for the hook.
+
+A config can list other configs under extends, which are merged beneath it
+with the same rules. Configs from other git repositories are read at a
+pinned ref and cached in the git directory; 'ghm config update' refreshes
+them.`,
+}
+
+func init() {
+	rootCmd.AddCommand(configCmd)
+}
@@ -0,0 +1,177 @@
+package cmd
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"reflect"
+	"sort"
+	"strings"
+
+	"githookd/internal/config"
+	"githookd/internal/runner"
+
+	"github.com/spf13/cobra"
+	"gopkg.in/yaml.v3"
+)
+
+var configShowCmd = &cobra.Command{
+	Use:   "show",
+	Short: "Show the merged configuration",
+	Long: `Print the configuration after merging all layers. With --origin, print
+every value on its own line together with the file it came from.`,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		originFlag, _ := cmd.Flags().GetBool("origin")
+
+		cfgPath := requireConfigFile()
+		cfg := loadLayeredConfigOrFail()
+
+		resolved, errs := cfg.Resolve()
+		if len(errs) > 0 {
+			fmt.Fprint(os.Stderr, runner.FormatErrors(errs))
+			os.Exit(1)
+		}
+
+		if !originFlag {
+			data, err := yaml.Marshal(cfg)
+			if err != nil {
+				return fmt.Errorf("failed to marshal config: %w", err)
+			}
+			fmt.Print(string(data))
+			return nil
+		}
+
+		fmt.Print(formatOrigins(cfg, resolved, filepath.Dir(cfgPath)))
+		return nil
+	},
+}
+
+func init() {
+	configCmd.AddCommand(configShowCmd)
+	configShowCmd.Flags().Bool("origin", false, "Show the file each value came from")
+}
+
+// originLine is one value of the merged configuration.
+type originLine struct {
+	key, value, origin string
+}
+
+// formatOrigins lists every value of the merged configuration with the
+// file it came from. Top-level values no layer sets show their default.
+// Origins inside root are shown relative to it.
+func formatOrigins(cfg *config.Config, resolved *config.ResolvedConfig, root string) string {
+	var lines []originLine
+	add := func(key, value, origin string) {
+		if origin != config.OriginDefault {
+			origin = displayPath(root, origin)
+		}
+		lines = append(lines, originLine{key, value, origin})
+	}
+
+	topLevel := []struct {
+		key, value string
+	}{
+		{"timeout", runner.FormatTimeout(resolved.Timeout)},
+		{"log_level", resolved.LogLevel.String()},
+		{"kill_grace", resolved.KillGrace.String()},
+		{"max_parallel", fmt.Sprint(resolved.MaxParallel)},
+		{"stash_unstaged", fmt.Sprint(resolved.StashUnstaged)},
+	}
+	for _, v := range topLevel {
+		add(v.key, v.value, cfg.Origin(v.key))
+	}
+
+	for _, section := range []struct {
+		name  string
+		lists map[string][]config.HookCommand
+	}{{"hooks", cfg.Hooks}, {"chains", cfg.Chains}} {
+		var names []string
+		for name := range section.lists {
+			names = append(names, name)
+		}
+		sort.Strings(names)
+		for _, name := range names {
+			for i, c := range section.lists[name] {
+				prefix := fmt.Sprintf("%s.%s[%d].", section.name, name, i)
+				flattenCommand(c, func(key, value string) {
+					field, _, _ := strings.Cut(key, ".")
+					origin := c.Origin(field)
+					if strings.HasPrefix(key, "env.") {
+						origin = c.Origin(key)
+					}
+					add(prefix+key, value, origin)
+				})
+			}
+		}
+	}
+
+	width := 0
+	for _, l := range lines {
+		width = max(width, len(l.key)+len(l.value)+2)
+	}
+	var b strings.Builder
+	for _, l := range lines {
+		fmt.Fprintf(&b, "%-*s  %s\n", width, l.key+": "+l.value, l.origin)
+	}
+	return b.String()
+}
+
+// flattenCommand calls fn with the key and value of every field the
+// command sets, in declaration order. A field a layer sets to its zero
+// value, such as parallel: false, is included. Maps and nested structs are
+// flattened into dotted keys.
+func flattenCommand(c config.HookCommand, fn func(key, value string)) {
+	v := reflect.ValueOf(c)
+	t := v.Type()
+	for i := 0; i < t.NumField(); i++ {
+		f := t.Field(i)
+		name, _, _ := strings.Cut(f.Tag.Get("yaml"), ",")
+		if !f.IsExported() || name == "" || name == "-" {
+			continue
+		}
+		if v.Field(i).IsZero() && c.Origin(name) == config.OriginDefault {
+			continue
+		}
+		flattenValue(name, v.Field(i), fn)
+	}
+}
+
+func flattenValue(prefix string, v reflect.Value, fn func(key, value string)) {
+	switch v.Kind() {
+	case reflect.Pointer:
+		if !v.IsNil() {
+			flattenValue(prefix, v.Elem(), fn)
+		}
+	case reflect.Struct:
+		t := v.Type()
+		for i := 0; i < t.NumField(); i++ {
+			f := t.Field(i)
+			name, _, _ := strings.Cut(f.Tag.Get("yaml"), ",")
+			if !f.IsExported() || name == "" || name == "-" || v.Field(i).IsZero() {
+				continue
+			}
+			flattenValue(joinKey(prefix, name), v.Field(i), fn)
+		}
+	case reflect.Map:
+		keys := v.MapKeys()
+		sort.Slice(keys, func(i, j int) bool { return keys[i].String() < keys[j].String() })
+		for _, k := range keys {
+			flattenValue(joinKey(prefix, k.String()), v.MapIndex(k), fn)
+		}
+	case reflect.Slice:
+		items := make([]string, v.Len())
+		for i := range items {
+			items[i] = fmt.Sprint(v.Index(i).Interface())
+		}
+		fn(prefix, "["+strings.Join(items, ", ")+"]")
+	default:
+		fn(prefix, fmt.Sprint(v.Interface()))
+	}
+}
+
+func joinKey(prefix, key string) string {
+	if prefix == "" {
+		return key
+	}
+	return prefix + "." + key
+}
@@ -0,0 +1,102 @@
+package cmd
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"githookd/internal/config"
+)
+
+func TestFormatOrigins(t *testing.T) {
+	dir := t.TempDir()
+	orig := config.SystemConfigPath
+	config.SystemConfigPath = ""
+	defer func() { config.SystemConfigPath = orig }()
+	t.Setenv("XDG_CONFIG_HOME", filepath.Join(dir, "xdg"))
+
+	repo := filepath.Join(dir, ".githooksrc.yml")
+	os.WriteFile(repo, []byte(`
+timeout: 1m
+hooks:
+  pre-commit:
+    - id: lint
+      run: npm run lint
+      glob: ["*.js", "*.ts"]
+      parallel: true
+      retries: 2
+`), 0644)
+	os.WriteFile(config.LocalPath(repo), []byte(`
+hooks:
+  pre-commit:
+    - id: lint
+      parallel: false
+      retries: 0
+      env:
+        CI: "1"
+`), 0644)
+
+	cfg, err := config.LoadLayered(repo, config.LoadOptions{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	resolved, errs := cfg.Resolve()
+	if len(errs) > 0 {
+		t.Fatal(errs)
+	}
+
+	out := formatOrigins(cfg, resolved, dir)
+	for _, want := range []string{
+		"timeout: 1m0s",
+		"log_level: warn",
+		"hooks.pre-commit[0].id: lint",
+		"hooks.pre-commit[0].run: npm run lint",
+		"hooks.pre-commit[0].glob: [*.js, *.ts]",
+		"hooks.pre-commit[0].env.CI: 1",
+		"hooks.pre-commit[0].parallel: false",
+		"hooks.pre-commit[0].retries: 0",
+	} {
+		if !strings.Contains(out, want) {
+			t.Errorf("output missing %q:\n%s", want, out)
+		}
+	}
+	if strings.Contains(out, "stage_fixed") {
+		t.Errorf("output shows stage_fixed, which no layer sets:\n%s", out)
+	}
+	lines := strings.Split(out, "\n")
+	for _, l := range lines {
+		switch {
+		case strings.HasPrefix(l, "timeout:"), strings.HasPrefix(l, "hooks.pre-commit[0].id:"), strings.HasPrefix(l, "hooks.pre-commit[0].run:"):
+			if !strings.HasSuffix(l, " .githooksrc.yml") {
+				t.Errorf("line %q should come from .githooksrc.yml", l)
+			}
+		case strings.HasPrefix(l, "log_level:"):
+			if !strings.HasSuffix(l, " "+config.OriginDefault) {
+				t.Errorf("line %q should be a default", l)
+			}
+		case strings.HasPrefix(l, "hooks.pre-commit[0].env.CI:"), strings.HasPrefix(l, "hooks.pre-commit[0].parallel:"), strings.HasPrefix(l, "hooks.pre-commit[0].retries:"):
+			if !strings.HasSuffix(l, " .githooksrc.local.yml") {
+				t.Errorf("line %q should come from .githooksrc.local.yml", l)
+			}
+		}
+	}
+}
+
+func TestEnsureGitignored(t *testing.T) {
+	dir := t.TempDir()
+	os.WriteFile(filepath.Join(dir, ".gitignore"), []byte("node_modules"), 0644)
+
+	added, err := ensureGitignored(dir, ".githooksrc.local.yml", false)
+	if err != nil || !added {
+		t.Fatalf("ensureGitignored() = %v, %v; want true, nil", added, err)
+	}
+	added, err = ensureGitignored(dir, ".githooksrc.local.yml", false)
+	if err != nil || added {
+		t.Fatalf("second ensureGitignored() = %v, %v; want false, nil", added, err)
+	}
+	data, _ := os.ReadFile(filepath.Join(dir, ".gitignore"))
+	if got, want := string(data), "node_modules\n/.githooksrc.local.yml\n"; got != want {
+		t.Errorf(".gitignore = %q, want %q", got, want)
+	}
+}
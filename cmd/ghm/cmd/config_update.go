@@ -0,0 +1,57 @@
+package cmd
+
+import (
+	"fmt"
+	"os"
+
+	"githookd/internal/config"
+
+	"github.com/spf13/cobra"
+)
+
+var configUpdateCmd = &cobra.Command{
+	Use:   "update",
+	Short: "Refresh configs extended from git repositories",
+	Long: `Read every config listed under extends from another git repository
+again at its ref, and update the cache in the git directory. Until then,
+ghm keeps using the commit the ref pointed to when it was first read.`,
+	Args: cobra.NoArgs,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		requireGitRepo()
+		cfgPath := requireConfigFile()
+
+		updates, err := config.UpdateExtends(cfgPath, loadOptions())
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+			os.Exit(1)
+		}
+
+		if len(updates) == 0 {
+			fmt.Println("No configs extended from git repositories.")
+			return nil
+		}
+		for _, u := range updates {
+			switch u.From {
+			case "":
+				fmt.Printf("  Fetched: %s (%s)\n", u.Source, shortCommit(u.To))
+			case u.To:
+				fmt.Printf("  Up to date: %s (%s)\n", u.Source, shortCommit(u.To))
+			default:
+				fmt.Printf("  Updated: %s (%s -> %s)\n", u.Source, shortCommit(u.From), shortCommit(u.To))
+			}
+		}
+		return nil
+	},
+}
+
+func init() {
+	configCmd.AddCommand(configUpdateCmd)
+}
+
+// shortCommit abbreviates a commit id for display.
+func shortCommit(commit string) string {
+	if len(commit) > 12 {
+		return commit[:12]
+	}
+	return commit
+}
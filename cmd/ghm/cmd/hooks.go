@@ -3,7 +3,9 @@ package cmd
 import (
 	"fmt"
 	"githookd/internal/config"
+	"githookd/internal/git"
 	"os"
+	"path/filepath"
 
 	"github.com/spf13/cobra"
 )
@@ -19,28 +21,89 @@ func init() {
 	rootCmd.AddCommand(hooksCmd)
 }
 
-// requireGitRepo checks that we're inside a git repository.
+// requireGitRepo checks that we're inside a git repository, which works
+// from any subdirectory, worktree or submodule, and returns its root.
 // Prints an error and exits if not.
-func requireGitRepo() {
-	if _, err := os.Stat(".git"); os.IsNotExist(err) {
-		// Try parent directories (simplified check — real validation is in git package)
+func requireGitRepo() string {
+	repoRoot, err := git.GetRepoRoot()
+	if err != nil {
 		fmt.Fprintln(os.Stderr, "Error: not a git repository")
 		os.Exit(1)
 	}
+	return repoRoot
+}
+
+// configPath returns the config file to use: the --config flag, then
+// $GHM_CONFIG, then .githooksrc.yml at the root of the repository. An
+// override relative to the current directory is made absolute. Exits if
+// the default is needed outside a git repository.
+func configPath() string {
+	path := configFlag
+	if path == "" {
+		path = os.Getenv(configEnv)
+	}
+	if path == "" {
+		return filepath.Join(requireGitRepo(), configFile)
+	}
+	if abs, err := filepath.Abs(path); err == nil {
+		path = abs
+	}
+	return path
 }
 
-// requireConfigFile checks that the config file exists.
-// Prints an error with a hint and exits if not.
-func requireConfigFile() {
-	if _, err := os.Stat(configFile); os.IsNotExist(err) {
-		fmt.Fprintf(os.Stderr, "Error: config file '%s' not found; run 'ghm install' first\n", configFile)
+// insideRepo reports whether path is inside the repository at repoRoot,
+// following symbolic links.
+func insideRepo(repoRoot, path string) bool {
+	if resolved, err := filepath.EvalSymlinks(repoRoot); err == nil {
+		repoRoot = resolved
+	}
+	if resolved, err := filepath.EvalSymlinks(path); err == nil {
+		path = resolved
+	} else if dir, err := filepath.EvalSymlinks(filepath.Dir(path)); err == nil {
+		path = filepath.Join(dir, filepath.Base(path))
+	}
+	rel, err := filepath.Rel(repoRoot, path)
+	return err == nil && filepath.IsLocal(rel)
+}
+
+// displayPath shortens path to be relative to the repository root when it
+// is inside it.
+func displayPath(repoRoot, path string) string {
+	if rel, err := filepath.Rel(repoRoot, path); err == nil && filepath.IsLocal(rel) {
+		return rel
+	}
+	return path
+}
+
+// requireConfigFile checks that the config file exists and returns its
+// path. Prints an error with a hint and exits if not.
+func requireConfigFile() string {
+	path := configPath()
+	if _, err := os.Stat(path); os.IsNotExist(err) {
+		fmt.Fprintf(os.Stderr, "Error: config file '%s' not found; run 'ghm install' first\n", path)
 		os.Exit(1)
 	}
+	return path
 }
 
-// loadConfigOrFail loads and returns the config, exiting on error.
-func loadConfigOrFail() *config.Config {
-	cfg, err := config.Load(configFile)
+// loadOptions returns how configs extended from git repositories are
+// loaded. They are cached in the git directory of the current repository,
+// if any.
+func loadOptions() config.LoadOptions {
+	var opts config.LoadOptions
+	if repoRoot, err := git.GetRepoRoot(); err == nil {
+		if p, err := git.GitPath(repoRoot, extendsCacheFile); err == nil {
+			opts.CachePath = p
+		}
+	}
+	return opts
+}
+
+// loadLayeredConfigOrFail loads the config merged with the system, user
+// and local layers, exiting on error. Use it to read the configuration;
+// commands that edit the file use openDocumentOrFail instead.
+func loadLayeredConfigOrFail() *config.Config {
+	cfg, err := config.LoadLayered(configPath(), loadOptions())
 	if err != nil {
 		fmt.Fprintf(os.Stderr, "Error: failed to parse config file: %v\n", err)
 		os.Exit(1)
@@ -48,10 +111,44 @@ func loadConfigOrFail() *config.Config {
 	return cfg
 }
 
-// saveConfigOrFail saves the config, exiting on error.
-func saveConfigOrFail(cfg *config.Config) {
-	if err := config.Save(configFile, cfg); err != nil {
+// openDocumentOrFail opens the repository config file alone for editing,
+// exiting on error.
+func openDocumentOrFail() *config.Document {
+	doc, err := config.OpenDocument(configPath())
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Error: failed to parse config file: %v\n", err)
+		os.Exit(1)
+	}
+	return doc
+}
+
+// saveDocumentOrFail saves the edited config file, exiting on error. It
+// warns when the edit could not be made in place and the whole file was
+// rewritten.
+func saveDocumentOrFail(doc *config.Document) {
+	if err := doc.Save(); err != nil {
 		fmt.Fprintf(os.Stderr, "Error: failed to save config file: %v\n", err)
 		os.Exit(1)
 	}
+	if doc.Rewritten() {
+		fmt.Fprintf(os.Stderr, "Warning: rewrote the whole config file, comments and formatting may have changed\n")
+	}
+}
+
+// matchesCommand reports whether a command is the one selected by the
+// --run or --id flag. Only one of the two is expected to be set. --run also
+// matches a script command by its path, such as .githooks/lint.sh.
+func matchesCommand(c config.HookCommand, runFlag, idFlag string) bool {
+	if idFlag != "" {
+		return c.ID == idFlag
+	}
+	return c.Label() == runFlag
+}
+
+// selectorLabel describes the --run or --id selector in messages.
+func selectorLabel(runFlag, idFlag string) string {
+	if idFlag != "" {
+		return "id " + idFlag
+	}
+	return runFlag
 }
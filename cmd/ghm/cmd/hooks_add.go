@@ -4,6 +4,8 @@ import (
 	"fmt"
 	"githookd/internal/config"
 	"os"
+	"path/filepath"
+	"strings"
 
 	"github.com/spf13/cobra"
 )
@@ -12,13 +14,17 @@ var hooksAddCmd = &cobra.Command{
 	Use:   "add <hook-name>",
 	Short: "Add a command to a hook",
 	Long: `Add a new command to the specified Git hook.
-The command will be appended to the end of the hook's command list.`,
+The command will be appended to the end of the hook's command list.
+Use --run for a shell command, or --script for a file in the .githooks
+directory; the script's #! line picks its interpreter.`,
 	Args: cobra.ExactArgs(1),
 	RunE: func(cmd *cobra.Command, args []string) error {
 		hookName := args[0]
 
 		runFlag, _ := cmd.Flags().GetString("run")
 		descFlag, _ := cmd.Flags().GetString("description")
+		idFlag, _ := cmd.Flags().GetString("id")
+		scriptFlag, _ := cmd.Flags().GetString("script")
 
 		// Validate hook name
 		if err := config.ValidateHookName(hookName); err != nil {
@@ -31,43 +37,71 @@ The command will be appended to the end of the hook's command list.`,
 			os.Exit(1)
 		}
 
-		// Validate run flag
-		if runFlag == "" {
-			fmt.Fprintln(os.Stderr, "Error: required flag \"run\" not set")
+		// Validate run and script flags
+		if runFlag != "" && scriptFlag != "" {
+			fmt.Fprintln(os.Stderr, "Error: --run and --script cannot be used together")
 			os.Exit(1)
 		}
+		if runFlag == "" && scriptFlag == "" {
+			fmt.Fprintln(os.Stderr, "Error: one of --run or --script is required")
+			os.Exit(1)
+		}
+		if idFlag != "" {
+			if err := config.ValidateCommandID(idFlag); err != nil {
+				fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+				os.Exit(1)
+			}
+		}
+
+		cfgPath := requireConfigFile()
+
+		// Scripts live next to the config file
+		if scriptFlag != "" {
+			scriptFlag = strings.TrimPrefix(filepath.ToSlash(scriptFlag), config.ScriptsDir+"/")
+			if info, err := os.Stat(filepath.Join(filepath.Dir(cfgPath), config.ScriptsDir, scriptFlag)); err != nil || info.IsDir() {
+				fmt.Fprintf(os.Stderr, "Error: script not found: %s/%s\n", config.ScriptsDir, scriptFlag)
+				os.Exit(1)
+			}
+		}
 
-		requireConfigFile()
-		cfg := loadConfigOrFail()
+		doc := openDocumentOrFail()
+		cfg := doc.Config()
 
-		// Initialize hooks map if nil
-		if cfg.Hooks == nil {
-			cfg.Hooks = make(map[string][]config.HookCommand)
+		newCmd := config.HookCommand{
+			ID:          idFlag,
+			Run:         runFlag,
+			Script:      scriptFlag,
+			Description: descFlag,
 		}
 
 		// Check for duplicate
 		for _, existing := range cfg.Hooks[hookName] {
-			if existing.Run == runFlag {
-				fmt.Fprintf(os.Stderr, "Error: command already exists for hook '%s': %s\n", hookName, runFlag)
+			if existing.Label() == newCmd.Label() {
+				fmt.Fprintf(os.Stderr, "Error: command already exists for hook '%s': %s\n", hookName, newCmd.Label())
+				os.Exit(1)
+			}
+			if idFlag != "" && existing.ID == idFlag {
+				fmt.Fprintf(os.Stderr, "Error: id '%s' is already used by a command for hook '%s': %s\n", idFlag, hookName, existing.Label())
 				os.Exit(1)
 			}
 		}
 
 		// Append new command
-		cfg.Hooks[hookName] = append(cfg.Hooks[hookName], config.HookCommand{
-			Run:         runFlag,
-			Description: descFlag,
-		})
+		if err := doc.AddCommand(hookName, newCmd); err != nil {
+			fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+			os.Exit(1)
+		}
 
-		saveConfigOrFail(cfg)
-		fmt.Printf("Added command to hook \"%s\": %s\n", hookName, runFlag)
+		saveDocumentOrFail(doc)
+		fmt.Printf("Added command to hook \"%s\": %s\n", hookName, newCmd.Label())
 		return nil
 	},
 }
 
 func init() {
 	hooksCmd.AddCommand(hooksAddCmd)
-	hooksAddCmd.Flags().StringP("run", "r", "", "The shell command to execute (required)")
+	hooksAddCmd.Flags().StringP("run", "r", "", "The shell command to execute")
+	hooksAddCmd.Flags().String("script", "", "A script file in .githooks to execute instead of --run")
 	hooksAddCmd.Flags().StringP("description", "d", "", "Human-readable description")
-	hooksAddCmd.MarkFlagRequired("run")
+	hooksAddCmd.Flags().String("id", "", "Unique id used to refer to the command")
 }
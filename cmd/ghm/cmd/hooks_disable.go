@@ -14,26 +14,28 @@ var hooksDisableCmd = &cobra.Command{
 	Short: "Disable a hook command",
 	Long: `Disable a command for the specified Git hook without removing it.
 Disabled commands are skipped during execution.
-Use --run to target a specific command, or --all to disable all commands.
+Use --run or --id to target a specific command, or --all to disable all commands.
 If the hook has only one command, no flag is needed.`,
 	Args: cobra.ExactArgs(1),
 	RunE: func(cmd *cobra.Command, args []string) error {
 		hookName := args[0]
 		runFlag, _ := cmd.Flags().GetString("run")
 		allFlag, _ := cmd.Flags().GetBool("all")
+		idFlag, _ := cmd.Flags().GetString("id")
 
 		if err := config.ValidateHookName(hookName); err != nil {
 			fmt.Fprintf(os.Stderr, "Error: unknown hook '%s'\n", hookName)
 			os.Exit(1)
 		}
 
-		if runFlag != "" && allFlag {
-			fmt.Fprintln(os.Stderr, "Error: --run and --all are mutually exclusive")
+		if (runFlag != "" && allFlag) || (idFlag != "" && allFlag) || (runFlag != "" && idFlag != "") {
+			fmt.Fprintln(os.Stderr, "Error: --run, --id and --all are mutually exclusive")
 			os.Exit(1)
 		}
 
 		requireConfigFile()
-		cfg := loadConfigOrFail()
+		doc := openDocumentOrFail()
+		cfg := doc.Config()
 
 		commands, ok := cfg.Hooks[hookName]
 		if !ok || len(commands) == 0 {
@@ -42,25 +44,27 @@ If the hook has only one command, no flag is needed.`,
 		}
 
 		// Single-command shorthand
-		if runFlag == "" && !allFlag {
+		if runFlag == "" && idFlag == "" && !allFlag {
 			if len(commands) == 1 {
 				allFlag = true
 			} else {
-				fmt.Fprintf(os.Stderr, "Error: hook '%s' has %d commands; use --run <command> or --all\n", hookName, len(commands))
+				fmt.Fprintf(os.Stderr, "Error: hook '%s' has %d commands; use --run <command>, --id <id> or --all\n", hookName, len(commands))
 				os.Exit(1)
 			}
 		}
 
 		changed := false
-		for i := range cfg.Hooks[hookName] {
-			c := &cfg.Hooks[hookName][i]
-			if allFlag || c.Run == runFlag {
+		for i, c := range commands {
+			if allFlag || matchesCommand(c, runFlag, idFlag) {
 				if c.Enabled == nil || *c.Enabled {
-					c.Enabled = config.BoolPtr(false)
+					if err := doc.SetEnabled(hookName, i, false); err != nil {
+						fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+						os.Exit(1)
+					}
 					changed = true
-					fmt.Printf("Disabled command for hook \"%s\": %s\n", hookName, c.Run)
+					fmt.Printf("Disabled command for hook \"%s\": %s\n", hookName, c.Label())
 				} else {
-					fmt.Printf("Command already disabled for hook \"%s\": %s\n", hookName, c.Run)
+					fmt.Printf("Command already disabled for hook \"%s\": %s\n", hookName, c.Label())
 				}
 				if !allFlag {
 					break
@@ -70,19 +74,19 @@ If the hook has only one command, no flag is needed.`,
 
 		if !allFlag && !changed {
 			found := false
-			for _, c := range cfg.Hooks[hookName] {
-				if c.Run == runFlag {
+			for _, c := range commands {
+				if matchesCommand(c, runFlag, idFlag) {
 					found = true
 					break
 				}
 			}
 			if !found {
-				fmt.Fprintf(os.Stderr, "Error: command not found for hook '%s': %s\n", hookName, runFlag)
+				fmt.Fprintf(os.Stderr, "Error: command not found for hook '%s': %s\n", hookName, selectorLabel(runFlag, idFlag))
 				os.Exit(1)
 			}
 		}
 
-		saveConfigOrFail(cfg)
+		saveDocumentOrFail(doc)
 		return nil
 	},
 }
@@ -90,5 +94,6 @@ If the hook has only one command, no flag is needed.`,
 func init() {
 	hooksCmd.AddCommand(hooksDisableCmd)
 	hooksDisableCmd.Flags().StringP("run", "r", "", "Specific command to disable")
+	hooksDisableCmd.Flags().String("id", "", "Id of the command to disable")
 	hooksDisableCmd.Flags().BoolP("all", "a", false, "Disable all commands under this hook")
 }
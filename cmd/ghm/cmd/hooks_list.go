@@ -29,7 +29,7 @@ var hooksListCmd = &cobra.Command{
 		}
 
 		requireConfigFile()
-		cfg := loadConfigOrFail()
+		cfg := loadLayeredConfigOrFail()
 
 		if len(cfg.Hooks) == 0 {
 			fmt.Println("No hooks configured.")
@@ -74,16 +74,39 @@ var hooksListCmd = &cobra.Command{
 				noun = "command"
 			}
 			fmt.Printf("%s (%d %s)\n", name, len(commands), noun)
+
+			// Show ids in their own column when any command has one
+			idWidth := 0
+			for _, c := range commands {
+				idWidth = max(idWidth, len(c.ID))
+			}
+
 			for _, c := range commands {
 				status := "enabled"
 				if !c.IsEnabled() {
 					status = "disabled"
 				}
+				id := ""
+				if idWidth > 0 {
+					id = fmt.Sprintf("%-*s  ", idWidth, c.ID)
+				}
 				desc := ""
 				if c.Description != "" {
 					desc = "  " + c.Description
 				}
-				fmt.Printf("  [%s]  %-40s%s\n", status, c.Run, desc)
+				fmt.Printf("  [%s]  %s%-40s%s\n", status, id, c.Label(), desc)
+
+				// Show what a chain reference expands to
+				if c.IsChainRef() {
+					expanded, err := cfg.ExpandChain(c.Chain)
+					if err != nil {
+						fmt.Printf("      error: %v\n", err)
+						continue
+					}
+					for _, e := range expanded {
+						fmt.Printf("      -> %s\n", e.Label())
+					}
+				}
 			}
 			fmt.Println()
 		}
@@ -93,9 +116,24 @@ var hooksListCmd = &cobra.Command{
 }
 
 type jsonHookCommand struct {
-	Run         string `json:"run"`
-	Description string `json:"description"`
-	Enabled     bool   `json:"enabled"`
+	ID          string            `json:"id,omitempty"`
+	Run         string            `json:"run"`
+	Script      string            `json:"script,omitempty"`
+	Chain       string            `json:"chain,omitempty"`
+	Expanded    []jsonHookCommand `json:"expanded,omitempty"`
+	Description string            `json:"description"`
+	Enabled     bool              `json:"enabled"`
+}
+
+func toJSONCommand(c config.HookCommand) jsonHookCommand {
+	return jsonHookCommand{
+		ID:          c.ID,
+		Run:         c.Run,
+		Script:      c.Script,
+		Chain:       c.Chain,
+		Description: c.Description,
+		Enabled:     c.IsEnabled(),
+	}
 }
 
 func printJSON(cfg *config.Config, hookNames []string) error {
@@ -104,11 +142,15 @@ func printJSON(cfg *config.Config, hookNames []string) error {
 	for _, name := range hookNames {
 		var cmds []jsonHookCommand
 		for _, c := range cfg.Hooks[name] {
-			cmds = append(cmds, jsonHookCommand{
-				Run:         c.Run,
-				Description: c.Description,
-				Enabled:     c.IsEnabled(),
-			})
+			jc := toJSONCommand(c)
+			if c.IsChainRef() {
+				// Invalid chains are reported by config validation
+				expanded, _ := cfg.ExpandChain(c.Chain)
+				for _, e := range expanded {
+					jc.Expanded = append(jc.Expanded, toJSONCommand(e))
+				}
+			}
+			cmds = append(cmds, jc)
 		}
 		output[name] = cmds
 	}
@@ -12,24 +12,30 @@ import (
 var hooksRemoveCmd = &cobra.Command{
 	Use:   "remove <hook-name>",
 	Short: "Remove a command from a hook",
-	Long:  `Remove a specific command from the specified Git hook by its exact run value.`,
+	Long:  `Remove a specific command from the specified Git hook by its id or its exact run value.`,
 	Args:  cobra.ExactArgs(1),
 	RunE: func(cmd *cobra.Command, args []string) error {
 		hookName := args[0]
 		runFlag, _ := cmd.Flags().GetString("run")
+		idFlag, _ := cmd.Flags().GetString("id")
 
 		if err := config.ValidateHookName(hookName); err != nil {
 			fmt.Fprintf(os.Stderr, "Error: unknown hook '%s'\n", hookName)
 			os.Exit(1)
 		}
 
-		if runFlag == "" {
-			fmt.Fprintln(os.Stderr, "Error: required flag \"run\" not set")
+		if runFlag == "" && idFlag == "" {
+			fmt.Fprintln(os.Stderr, "Error: one of --run or --id is required")
+			os.Exit(1)
+		}
+		if runFlag != "" && idFlag != "" {
+			fmt.Fprintln(os.Stderr, "Error: --run and --id are mutually exclusive")
 			os.Exit(1)
 		}
 
 		requireConfigFile()
-		cfg := loadConfigOrFail()
+		doc := openDocumentOrFail()
+		cfg := doc.Config()
 
 		commands, ok := cfg.Hooks[hookName]
 		if !ok || len(commands) == 0 {
@@ -37,37 +43,32 @@ var hooksRemoveCmd = &cobra.Command{
 			os.Exit(1)
 		}
 
-		// Find and remove
+		// Find and remove, from the end so indexes stay valid
 		found := false
-		var remaining []config.HookCommand
-		for _, c := range commands {
-			if c.Run == runFlag {
-				found = true
+		for i := len(commands) - 1; i >= 0; i-- {
+			if !matchesCommand(commands[i], runFlag, idFlag) {
 				continue
 			}
-			remaining = append(remaining, c)
+			found = true
+			if err := doc.RemoveCommand(hookName, i); err != nil {
+				fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+				os.Exit(1)
+			}
 		}
 
 		if !found {
-			fmt.Fprintf(os.Stderr, "Error: command not found for hook '%s': %s\n", hookName, runFlag)
+			fmt.Fprintf(os.Stderr, "Error: command not found for hook '%s': %s\n", hookName, selectorLabel(runFlag, idFlag))
 			os.Exit(1)
 		}
 
-		// Clean up empty key
-		if len(remaining) == 0 {
-			delete(cfg.Hooks, hookName)
-		} else {
-			cfg.Hooks[hookName] = remaining
-		}
-
-		saveConfigOrFail(cfg)
-		fmt.Printf("Removed command from hook \"%s\": %s\n", hookName, runFlag)
+		saveDocumentOrFail(doc)
+		fmt.Printf("Removed command from hook \"%s\": %s\n", hookName, selectorLabel(runFlag, idFlag))
 		return nil
 	},
 }
 
 func init() {
 	hooksCmd.AddCommand(hooksRemoveCmd)
-	hooksRemoveCmd.Flags().StringP("run", "r", "", "Exact run value of the command to remove (required)")
-	hooksRemoveCmd.MarkFlagRequired("run")
+	hooksRemoveCmd.Flags().StringP("run", "r", "", "Exact run value of the command to remove")
+	hooksRemoveCmd.Flags().String("id", "", "Id of the command to remove")
 }
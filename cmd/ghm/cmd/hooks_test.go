@@ -3,6 +3,7 @@ package cmd
 import (
 	"githookd/internal/config"
 	"os"
+	"os/exec"
 	"path/filepath"
 	"testing"
 )
@@ -248,3 +249,125 @@ hooks:
 		t.Fatal("should be enabled after save/load")
 	}
 }
+
+func TestMatchesCommand(t *testing.T) {
+	c := config.HookCommand{ID: "lint", Run: "npm run lint"}
+
+	if !matchesCommand(c, "npm run lint", "") {
+		t.Error("expected --run to match the run string")
+	}
+	if !matchesCommand(c, "", "lint") {
+		t.Error("expected --id to match the id")
+	}
+	if matchesCommand(c, "", "npm run lint") {
+		t.Error("--id should not match the run string")
+	}
+	if matchesCommand(config.HookCommand{Run: "npm test"}, "", "test") {
+		t.Error("--id should not match a command without an id")
+	}
+	if !matchesCommand(config.HookCommand{Script: "lint.sh"}, ".githooks/lint.sh", "") {
+		t.Error("expected --run to match a script command by its path")
+	}
+}
+
+func TestConfigPath(t *testing.T) {
+	tmpDir := t.TempDir()
+	gitInit := exec.Command("git", "init", "-q", tmpDir)
+	if out, err := gitInit.CombinedOutput(); err != nil {
+		t.Fatalf("git init: %v\n%s", err, out)
+	}
+	root, err := filepath.EvalSymlinks(tmpDir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	sub := filepath.Join(root, "a", "b")
+	if err := os.MkdirAll(sub, 0755); err != nil {
+		t.Fatal(err)
+	}
+	origDir, _ := os.Getwd()
+	defer os.Chdir(origDir)
+	if err := os.Chdir(sub); err != nil {
+		t.Fatal(err)
+	}
+
+	t.Setenv(configEnv, "")
+	if got, want := configPath(), filepath.Join(root, ".githooksrc.yml"); got != want {
+		t.Errorf("configPath() = %q, want %q", got, want)
+	}
+
+	t.Setenv(configEnv, "ci.yml")
+	if got, want := configPath(), filepath.Join(sub, "ci.yml"); got != want {
+		t.Errorf("configPath() with %s = %q, want %q", configEnv, got, want)
+	}
+
+	configFlag = "/etc/ghm.yml"
+	defer func() { configFlag = "" }()
+	if got := configPath(); got != "/etc/ghm.yml" {
+		t.Errorf("configPath() with --config = %q, want /etc/ghm.yml", got)
+	}
+}
+
+func TestInstallUninstall_FromSubdirectory(t *testing.T) {
+	parent := t.TempDir()
+	tmpDir := filepath.Join(parent, "repo")
+	gitInit := exec.Command("git", "init", "-q", tmpDir)
+	if out, err := gitInit.CombinedOutput(); err != nil {
+		t.Fatalf("git init: %v\n%s", err, out)
+	}
+	root, err := filepath.EvalSymlinks(tmpDir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	sub := filepath.Join(root, "a", "b")
+	if err := os.MkdirAll(sub, 0755); err != nil {
+		t.Fatal(err)
+	}
+	origDir, _ := os.Getwd()
+	defer os.Chdir(origDir)
+	if err := os.Chdir(sub); err != nil {
+		t.Fatal(err)
+	}
+	t.Setenv(configEnv, "")
+
+	hook := filepath.Join(root, ".git", "hooks", "pre-commit")
+	if err := installCmd.RunE(installCmd, nil); err != nil {
+		t.Fatalf("install error = %v", err)
+	}
+	if info, err := os.Lstat(hook); err != nil || info.Mode()&os.ModeSymlink == 0 {
+		t.Fatalf("pre-commit hook not installed in the repository: %v", err)
+	}
+	if _, err := os.Stat(filepath.Join(parent, ".git")); !os.IsNotExist(err) {
+		t.Errorf("install wrote outside the repository: %v", err)
+	}
+
+	if err := uninstallCmd.RunE(uninstallCmd, nil); err != nil {
+		t.Fatalf("uninstall error = %v", err)
+	}
+	if _, err := os.Lstat(hook); !os.IsNotExist(err) {
+		t.Errorf("pre-commit hook left after uninstall: %v", err)
+	}
+}
+
+func TestInsideRepo(t *testing.T) {
+	root := t.TempDir()
+	link := filepath.Join(t.TempDir(), "link")
+	if err := os.Symlink(root, link); err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		path string
+		want bool
+	}{
+		{filepath.Join(root, ".githooksrc.yml"), true},
+		{filepath.Join(root, "cfg", "ci.yml"), true},
+		{filepath.Join(link, ".githooksrc.yml"), true},
+		{filepath.Join(filepath.Dir(root), "shared.yml"), false},
+		{"/etc/ghm.yml", false},
+	}
+	for _, tt := range tests {
+		if got := insideRepo(root, tt.path); got != tt.want {
+			t.Errorf("insideRepo(%q) = %v, want %v", tt.path, got, tt.want)
+		}
+	}
+}
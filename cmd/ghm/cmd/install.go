@@ -6,6 +6,7 @@ import (
 	"githookd/internal/git"
 	"os"
 	"path/filepath"
+	"strings"
 	"time"
 
 	"github.com/spf13/cobra"
@@ -37,7 +38,8 @@ and sets up the Git hooks.`,
 			fmt.Fprintf(os.Stderr, "Error: %v\n", err)
 			os.Exit(1)
 		}
-		_ = repoRoot
+		cfgPath := configPath()
+		scriptsDir := filepath.Join(repoRoot, githooksDir)
 
 		if dryRun {
 			fmt.Println("Dry run mode: no changes will be made.")
@@ -45,7 +47,7 @@ and sets up the Git hooks.`,
 
 		// Create .githooks directory
 		if !dryRun {
-			if err := os.MkdirAll(githooksDir, 0755); err != nil {
+			if err := os.MkdirAll(scriptsDir, 0755); err != nil {
 				fmt.Fprintf(os.Stderr, "Error creating %s directory: %v\n", githooksDir, err)
 				os.Exit(1)
 			}
@@ -53,20 +55,34 @@ and sets up the Git hooks.`,
 		fmt.Printf("Created %s directory.\n", githooksDir)
 
 		// Create .githooksrc.yml file
-		if _, err := os.Stat(configFile); os.IsNotExist(err) {
+		cfgName := displayPath(repoRoot, cfgPath)
+		if _, err := os.Stat(cfgPath); os.IsNotExist(err) {
 			if !dryRun {
-				if err := os.WriteFile(configFile, []byte(defaultConfigFile), 0644); err != nil {
-					fmt.Fprintf(os.Stderr, "Error creating %s file: %v\n", configFile, err)
+				if err := os.WriteFile(cfgPath, []byte(defaultConfigFile), 0644); err != nil {
+					fmt.Fprintf(os.Stderr, "Error creating %s file: %v\n", cfgName, err)
 					os.Exit(1)
 				}
 			}
-			fmt.Printf("Created %s file.\n", configFile)
+			fmt.Printf("Created %s file.\n", cfgName)
 		} else {
-			fmt.Printf("%s file already exists.\n", configFile)
+			fmt.Printf("%s file already exists.\n", cfgName)
+		}
+
+		// Keep the local override out of version control
+		localName := displayPath(repoRoot, config.LocalPath(cfgPath))
+		if filepath.IsLocal(localName) {
+			added, err := ensureGitignored(repoRoot, filepath.ToSlash(localName), dryRun)
+			if err != nil {
+				fmt.Fprintf(os.Stderr, "Error updating .gitignore: %v\n", err)
+				os.Exit(1)
+			}
+			if added {
+				fmt.Printf("Added %s to .gitignore.\n", localName)
+			}
 		}
 
 		// Install hooks
-		hooksDir, err := git.GetHooksDir()
+		hooksDir, err := git.GitPath(repoRoot, "hooks")
 		if err != nil {
 			fmt.Fprintf(os.Stderr, "Error: %v\n", err)
 			os.Exit(1)
@@ -101,6 +117,32 @@ func init() {
 	installCmd.Flags().Bool("force", false, "Reinstall all hooks even if already managed by ghm")
 }
 
+// ensureGitignored adds an entry for the repository-relative path to the
+// .gitignore at the root unless it is already listed. It reports whether
+// the entry was added.
+func ensureGitignored(repoRoot, path string, dryRun bool) (bool, error) {
+	gitignore := filepath.Join(repoRoot, ".gitignore")
+	data, err := os.ReadFile(gitignore)
+	if err != nil && !os.IsNotExist(err) {
+		return false, err
+	}
+	entry := "/" + path
+	for _, line := range strings.Split(string(data), "\n") {
+		line = strings.TrimSpace(line)
+		if line == path || line == entry {
+			return false, nil
+		}
+	}
+	if dryRun {
+		return true, nil
+	}
+	if len(data) > 0 && !strings.HasSuffix(string(data), "\n") {
+		data = append(data, '\n')
+	}
+	data = append(data, entry+"\n"...)
+	return true, os.WriteFile(gitignore, data, 0644)
+}
+
 // doInstall handles the actual hook installation logic.
 func doInstall(hooksDir, ghmPath string, dryRun, force bool) (installed, skipped, backedUp int, err error) {
 	// Ensure hooks directory exists
@@ -7,6 +7,20 @@ import (
 	"github.com/spf13/cobra"
 )
 
+// invokedAsHook is set when ghm runs through a hook symlink, i.e. when
+// Git itself is the caller.
+var invokedAsHook bool
+
+// configFlag holds the --config flag. See configPath.
+var configFlag string
+
+// configEnv names the environment variable that overrides the config file.
+const configEnv = "GHM_CONFIG"
+
+// extendsCacheFile caches configs extended from git repositories, relative
+// to the git directory.
+const extendsCacheFile = "githookd/extends.json"
+
 var rootCmd = &cobra.Command{
 	Use:   "ghm",
 	Short: "githookd is a Git hook manager",
@@ -14,6 +28,10 @@ var rootCmd = &cobra.Command{
 your hooks from a version-controlled configuration file.`,
 }
 
+func init() {
+	rootCmd.PersistentFlags().StringVar(&configFlag, "config", "", "Path to the config file (default: .githooksrc.yml at the repository root, or $"+configEnv+")")
+}
+
 func Execute() {
 	if err := rootCmd.Execute(); err != nil {
 		fmt.Println(err)
@@ -28,3 +46,11 @@ func ExecuteWithArgs(args []string) {
 		os.Exit(1)
 	}
 }
+
+// ExecuteHook runs the given hook on behalf of Git, as when ghm is invoked
+// through a symlink in the hooks directory.
+func ExecuteHook(hookName string, args []string) {
+	invokedAsHook = true
+	// "--" keeps Git's arguments from being parsed as ghm flags.
+	ExecuteWithArgs(append([]string{"run", hookName, "--"}, args...))
+}
@@ -1,12 +1,19 @@
 package cmd
 
 import (
+	"encoding/json"
+	"errors"
 	"fmt"
 	"githookd/internal/config"
 	"githookd/internal/git"
 	"githookd/internal/logging"
 	"githookd/internal/runner"
+	"io"
 	"os"
+	"os/signal"
+	"path/filepath"
+	"strings"
+	"syscall"
 
 	"github.com/spf13/cobra"
 )
@@ -15,13 +22,51 @@ var runCmd = &cobra.Command{
 	Use:   "run [hook] [args...]",
 	Short: "Run the specified hook",
 	Long: `This command runs the specified hook. This is useful for testing your hooks
-or for running them in a CI/CD environment.`,
+or for running them in a CI/CD environment.
+
+Commands with file filters or {staged_files} work on the staged files by
+default, or on pre-push, on the files changed by the pushed commits. Use
+--all-files, --files, or --from-ref/--to-ref to run them on a different set
+of files, e.g. the files changed by a pull request in CI.
+
+Individual commands can be bypassed with --skip or GHM_SKIP=id1,id2 and
+selected with --only, by id or run string. GHM=0 disables ghm entirely.`,
 	Args: cobra.MinimumNArgs(1),
 	RunE: func(cmd *cobra.Command, args []string) error {
 		hookName := args[0]
 		hookArgs := args[1:]
 
-		cfg, err := config.Load(configFile)
+		// GHM=0 disables ghm entirely for one invocation.
+		if os.Getenv("GHM") == "0" {
+			return nil
+		}
+
+		allFiles, _ := cmd.Flags().GetBool("all-files")
+		filesFlag, _ := cmd.Flags().GetStringSlice("files")
+		fromRef, _ := cmd.Flags().GetString("from-ref")
+		toRef, _ := cmd.Flags().GetString("to-ref")
+		skipFlag, _ := cmd.Flags().GetStringSlice("skip")
+		onlyFlag, _ := cmd.Flags().GetStringSlice("only")
+		dryRun, _ := cmd.Flags().GetBool("dry-run")
+		jsonFlag, _ := cmd.Flags().GetBool("json")
+
+		if jsonFlag && !dryRun {
+			fmt.Fprintln(os.Stderr, "Error: --json requires --dry-run")
+			os.Exit(1)
+		}
+
+		if err := validateFileSetFlags(allFiles, filesFlag, fromRef, toRef); err != nil {
+			fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+			os.Exit(1)
+		}
+
+		repoRoot, err := git.GetRepoRoot()
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "Error getting repo root: %v\n", err)
+			os.Exit(1)
+		}
+
+		cfg, err := config.LoadLayered(configPath(), loadOptions())
 		if err != nil {
 			fmt.Fprintf(os.Stderr, "Error loading config: %v\n", err)
 			os.Exit(1)
@@ -35,26 +80,111 @@ or for running them in a CI/CD environment.`,
 
 		// Set up logging
 		slogLevel := logging.ConfigLevelToSlog(resolved.LogLevel)
+		if logLevelFlag, _ := cmd.Flags().GetString("log-level"); logLevelFlag != "" {
+			slogLevel, err = logging.ParseLevel(logLevelFlag)
+			if err != nil {
+				fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+				os.Exit(1)
+			}
+		}
 		logging.Setup(slogLevel, os.Stderr)
 
-		repoRoot, err := git.GetRepoRoot()
+		commands, ok := resolved.Hooks[hookName]
+		if !ok && !dryRun {
+			// No commands for this hook, exit successfully.
+			return nil
+		}
+
+		opts := runner.Options{
+			RepoRoot:    repoRoot,
+			HookArgs:    hookArgs,
+			MaxParallel: resolved.MaxParallel,
+			Skip:        skipRules(os.Getenv("GHM_SKIP"), skipFlag),
+			Only:        onlyFlag,
+			CI:          !invokedAsHook && isCI(),
+			Version:     buildVersion,
+		}
+
+		opts.Branch, err = git.GetCurrentBranch()
 		if err != nil {
-			fmt.Fprintf(os.Stderr, "Error getting repo root: %v\n", err)
+			fmt.Fprintf(os.Stderr, "Error: %v\n", err)
 			os.Exit(1)
 		}
 
-		commands, ok := resolved.Hooks[hookName]
-		if !ok {
-			// No commands for this hook, exit successfully.
+		opts.Files, opts.Staged, err = selectFileSet(hookName, repoRoot, allFiles, filesFlag, fromRef, toRef)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+			os.Exit(1)
+		}
+
+		if dryRun {
+			plans := runner.PlanHook(hookName, commands, opts)
+			if jsonFlag {
+				return printPlansJSON(hookName, plans)
+			}
+			fmt.Print(runner.FormatPlans(hookName, plans))
 			return nil
 		}
 
-		if err := runner.RunHook(hookName, commands, repoRoot, hookArgs); err != nil {
-			if hookErr, ok := err.(*runner.HookError); ok {
-				fmt.Fprint(os.Stderr, hookErr.FormatReport())
+		// Git writes ref lists and rewritten SHAs to the hook's stdin. Read
+		// them once so every command in the chain sees the same bytes.
+		if invokedAsHook && config.ReceivesStdin(hookName) {
+			stdin, err := io.ReadAll(os.Stdin)
+			if err != nil {
+				fmt.Fprintf(os.Stderr, "Error reading hook input: %v\n", err)
+				os.Exit(1)
+			}
+			opts.Stdin = stdin
+		}
+
+		// Commands run in their own process groups, so a Ctrl-C in the
+		// terminal only reaches ghm. Forward it to them and wait for them
+		// to exit instead of leaving them orphaned.
+		signals := make(chan os.Signal, 2)
+		signal.Notify(signals, os.Interrupt, syscall.SIGTERM)
+		defer signal.Stop(signals)
+		opts.Interrupt = signals
+
+		// Optionally set unstaged changes aside so pre-commit commands see
+		// exactly what is being committed.
+		var stash *git.Stash
+		if resolved.StashUnstaged && hookName == "pre-commit" && opts.Staged {
+			stash, err = git.StashUnstaged(repoRoot)
+			if err != nil {
+				fmt.Fprintf(os.Stderr, "Error stashing unstaged changes: %v\n", err)
+				os.Exit(1)
+			}
+		}
+
+		summary, runErr := runner.RunHook(hookName, commands, opts)
+
+		interrupted := errors.Is(runErr, runner.ErrInterrupted)
+		if runErr != nil && !interrupted {
+			if report, ok := runErr.(interface{ FormatReport() string }); ok {
+				fmt.Fprint(os.Stderr, report.FormatReport())
 			} else {
-				fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+				fmt.Fprintf(os.Stderr, "Error: %v\n", runErr)
 			}
+		}
+		fmt.Fprint(os.Stderr, summary.Format())
+
+		if stash != nil {
+			if err := stash.Restore(); err != nil {
+				fmt.Fprintf(os.Stderr, "Error restoring unstaged changes: %v\n", err)
+				os.Exit(1)
+			}
+			if stash.Conflicted {
+				fmt.Fprintln(os.Stderr, "Warning: unstaged changes conflicted with changes made by hook commands; the affected files were restored as they were before the hook, and only the hook changes that were staged are kept.")
+			}
+			interrupted = interrupted || stash.Interrupted
+		}
+
+		if interrupted {
+			fmt.Fprintln(os.Stderr, "Interrupted.")
+			os.Exit(130)
+		}
+
+		if runErr != nil {
 			os.Exit(1)
 		}
 
@@ -64,4 +194,167 @@ or for running them in a CI/CD environment.`,
 
 func init() {
 	rootCmd.AddCommand(runCmd)
+	runCmd.Flags().Bool("all-files", false, "Run file-based commands on every tracked file")
+	runCmd.Flags().StringSlice("files", nil, "Run file-based commands on these files (comma-separated or repeated)")
+	runCmd.Flags().String("from-ref", "", "Run file-based commands on files changed since this ref")
+	runCmd.Flags().String("to-ref", "", "End of the --from-ref range (default HEAD)")
+	runCmd.Flags().StringSlice("skip", nil, "Skip commands by id or run string (also GHM_SKIP=a,b)")
+	runCmd.Flags().StringSlice("only", nil, "Run only the commands with these ids or run strings")
+	runCmd.Flags().Bool("dry-run", false, "Show how each command would run, or why it would be skipped, without running anything")
+	runCmd.Flags().Bool("json", false, "With --dry-run, print the plan as JSON")
+	runCmd.Flags().String("log-level", "", "Override the configured log level (debug, info, warn, error)")
+}
+
+type jsonPlan struct {
+	ID      string            `json:"id,omitempty"`
+	Run     string            `json:"run"`
+	Skipped bool              `json:"skipped"`
+	Reason  string            `json:"reason,omitempty"`
+	Argv    [][]string        `json:"argv,omitempty"`
+	Dir     string            `json:"dir,omitempty"`
+	Needs   []string          `json:"needs,omitempty"`
+	Env     map[string]string `json:"env,omitempty"`
+	Timeout string            `json:"timeout,omitempty"`
+	Files   []string          `json:"files,omitempty"`
+	Error   string            `json:"error,omitempty"`
+}
+
+// printPlansJSON prints a hook's dry-run plans as JSON.
+func printPlansJSON(hookName string, plans []runner.Plan) error {
+	output := struct {
+		Hook     string     `json:"hook"`
+		Commands []jsonPlan `json:"commands"`
+	}{Hook: hookName, Commands: []jsonPlan{}}
+
+	for _, p := range plans {
+		jp := jsonPlan{
+			ID:      p.Command.ID,
+			Run:     p.Command.Run,
+			Skipped: p.Skipped,
+			Reason:  p.Reason,
+			Argv:    p.Argv,
+			Dir:     p.Dir,
+			Needs:   p.Command.Needs,
+			Files:   p.Files,
+			Error:   p.Error,
+		}
+		if !p.Skipped {
+			jp.Timeout = runner.FormatTimeout(p.Timeout)
+		}
+		if len(p.Env) > 0 {
+			jp.Env = make(map[string]string, len(p.Env))
+			for _, kv := range p.Env {
+				name, value, _ := strings.Cut(kv, "=")
+				jp.Env[name] = value
+			}
+		}
+		output.Commands = append(output.Commands, jp)
+	}
+
+	data, err := json.MarshalIndent(output, "", "  ")
+	if err != nil {
+		return fmt.Errorf("failed to marshal JSON: %w", err)
+	}
+	fmt.Println(string(data))
+	return nil
+}
+
+// isCI reports whether ghm appears to be running in a CI environment. Most
+// CI services set CI; the others are checked by their own variables.
+func isCI() bool {
+	switch strings.ToLower(os.Getenv("CI")) {
+	case "", "0", "false":
+	default:
+		return true
+	}
+	for _, name := range []string{"GITHUB_ACTIONS", "GITLAB_CI", "BUILDKITE", "CIRCLECI", "JENKINS_URL", "TF_BUILD"} {
+		if os.Getenv(name) != "" {
+			return true
+		}
+	}
+	return false
+}
+
+// skipRules builds the runner's skip rules from the comma-separated
+// GHM_SKIP value and the --skip flag.
+func skipRules(env string, flag []string) []runner.SkipRule {
+	var rules []runner.SkipRule
+	for _, selector := range strings.Split(env, ",") {
+		if selector = strings.TrimSpace(selector); selector != "" {
+			rules = append(rules, runner.SkipRule{Selector: selector, Reason: "GHM_SKIP"})
+		}
+	}
+	for _, selector := range flag {
+		rules = append(rules, runner.SkipRule{Selector: selector, Reason: "--skip"})
+	}
+	return rules
+}
+
+// validateFileSetFlags checks that at most one way of choosing files was given.
+func validateFileSetFlags(allFiles bool, files []string, fromRef, toRef string) error {
+	if toRef != "" && fromRef == "" {
+		return fmt.Errorf("--to-ref requires --from-ref")
+	}
+
+	set := 0
+	if allFiles {
+		set++
+	}
+	if len(files) > 0 {
+		set++
+	}
+	if fromRef != "" {
+		set++
+	}
+	if set > 1 {
+		return fmt.Errorf("--all-files, --files and --from-ref are mutually exclusive")
+	}
+	return nil
+}
+
+// selectFileSet returns the files the hook's commands apply to and whether
+// they are the staged files. Without any file flags, pre-commit works on
+// the staged files and other hooks have no file set here; the runner finds
+// the pushed files for pre-push itself.
+func selectFileSet(hookName, repoRoot string, allFiles bool, files []string, fromRef, toRef string) ([]string, bool, error) {
+	switch {
+	case allFiles:
+		all, err := git.GetAllFiles()
+		return all, false, err
+	case len(files) > 0:
+		rel, err := repoRelativePaths(repoRoot, files)
+		return rel, false, err
+	case fromRef != "":
+		if toRef == "" {
+			toRef = "HEAD"
+		}
+		changed, err := git.GetChangedFiles(fromRef, toRef)
+		return changed, false, err
+	case hookName == "pre-commit":
+		staged, err := git.GetStagedFiles()
+		return staged, err == nil, err
+	default:
+		return nil, false, nil
+	}
+}
+
+// repoRelativePaths converts paths given on the command line, relative to
+// the current directory, into slash-separated paths relative to repoRoot.
+func repoRelativePaths(repoRoot string, paths []string) ([]string, error) {
+	rel := make([]string, 0, len(paths))
+	for _, p := range paths {
+		abs, err := filepath.Abs(p)
+		if err != nil {
+			return nil, fmt.Errorf("invalid path %q: %w", p, err)
+		}
+		if resolved, err := filepath.EvalSymlinks(abs); err == nil {
+			abs = resolved
+		}
+		r, err := filepath.Rel(repoRoot, abs)
+		if err != nil || r == ".." || strings.HasPrefix(r, ".."+string(filepath.Separator)) {
+			return nil, fmt.Errorf("path %q is outside the repository", p)
+		}
+		rel = append(rel, filepath.ToSlash(r))
+	}
+	return rel, nil
 }
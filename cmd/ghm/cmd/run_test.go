@@ -0,0 +1,109 @@
+package cmd
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestValidateFileSetFlags(t *testing.T) {
+	tests := []struct {
+		name     string
+		allFiles bool
+		files    []string
+		fromRef  string
+		toRef    string
+		wantErr  bool
+	}{
+		{"none", false, nil, "", "", false},
+		{"all files", true, nil, "", "", false},
+		{"files", false, []string{"a.go"}, "", "", false},
+		{"range", false, nil, "origin/main", "HEAD", false},
+		{"to-ref without from-ref", false, nil, "", "HEAD", true},
+		{"all files and files", true, []string{"a.go"}, "", "", true},
+		{"files and range", false, []string{"a.go"}, "origin/main", "", true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := validateFileSetFlags(tt.allFiles, tt.files, tt.fromRef, tt.toRef)
+			if (err != nil) != tt.wantErr {
+				t.Errorf("validateFileSetFlags() error = %v, wantErr %v", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestRepoRelativePaths(t *testing.T) {
+	root, err := filepath.EvalSymlinks(t.TempDir())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := os.MkdirAll(filepath.Join(root, "sub"), 0755); err != nil {
+		t.Fatal(err)
+	}
+
+	origDir, _ := os.Getwd()
+	if err := os.Chdir(filepath.Join(root, "sub")); err != nil {
+		t.Fatal(err)
+	}
+	defer os.Chdir(origDir)
+
+	got, err := repoRelativePaths(root, []string{"a.go", "../b.go", filepath.Join(root, "c", "d.go")})
+	if err != nil {
+		t.Fatalf("repoRelativePaths() error = %v", err)
+	}
+	want := []string{"sub/a.go", "b.go", "c/d.go"}
+	for i := range want {
+		if got[i] != want[i] {
+			t.Errorf("path %d = %q, want %q", i, got[i], want[i])
+		}
+	}
+
+	if _, err := repoRelativePaths(root, []string{"../../outside.go"}); err == nil {
+		t.Error("expected an error for a path outside the repository")
+	}
+}
+
+func TestSkipRules(t *testing.T) {
+	rules := skipRules(" lint, ,tests", []string{"npm run build"})
+
+	want := []struct{ selector, reason string }{
+		{"lint", "GHM_SKIP"},
+		{"tests", "GHM_SKIP"},
+		{"npm run build", "--skip"},
+	}
+	if len(rules) != len(want) {
+		t.Fatalf("got %d rules, want %d: %v", len(rules), len(want), rules)
+	}
+	for i, w := range want {
+		if rules[i].Selector != w.selector || rules[i].Reason != w.reason {
+			t.Errorf("rule %d = %+v, want %s (%s)", i, rules[i], w.selector, w.reason)
+		}
+	}
+}
+
+func TestIsCI(t *testing.T) {
+	for _, name := range []string{"CI", "GITHUB_ACTIONS", "GITLAB_CI", "BUILDKITE", "CIRCLECI", "JENKINS_URL", "TF_BUILD"} {
+		t.Setenv(name, "")
+	}
+	if isCI() {
+		t.Error("isCI() = true with no CI variables set")
+	}
+
+	t.Setenv("CI", "false")
+	if isCI() {
+		t.Error("isCI() = true with CI=false")
+	}
+
+	t.Setenv("CI", "true")
+	if !isCI() {
+		t.Error("isCI() = false with CI=true")
+	}
+
+	t.Setenv("CI", "")
+	t.Setenv("GITHUB_ACTIONS", "true")
+	if !isCI() {
+		t.Error("isCI() = false with GITHUB_ACTIONS=true")
+	}
+}
@@ -20,12 +20,16 @@ By default, .githooksrc.yml and .githooks/ are preserved.`,
 		removeConfig, _ := cmd.Flags().GetBool("remove-config")
 
 		// Verify we're in a git repo
-		if _, err := git.GetRepoRoot(); err != nil {
+		repoRoot, err := git.GetRepoRoot()
+		if err != nil {
 			fmt.Fprintf(os.Stderr, "Error: %v\n", err)
 			os.Exit(1)
 		}
+		cfgPath := configPath()
+		cfgName := displayPath(repoRoot, cfgPath)
+		scriptsDir := filepath.Join(repoRoot, githooksDir)
 
-		hooksDir, err := git.GetHooksDir()
+		hooksDir, err := git.GitPath(repoRoot, "hooks")
 		if err != nil {
 			fmt.Fprintf(os.Stderr, "Error: %v\n", err)
 			os.Exit(1)
@@ -58,28 +62,34 @@ By default, .githooksrc.yml and .githooks/ are preserved.`,
 				removed, verb, restored, verb, skipped, verb)
 		}
 
-		// Handle config removal
+		// Handle config removal. A config from --config or GHM_CONFIG may be
+		// shared with other repositories, so only one inside this repository
+		// is removed.
 		if removeConfig {
+			if !insideRepo(repoRoot, cfgPath) {
+				fmt.Printf("Left in place: %s (outside the repository)\n", cfgName)
+			} else if dryRun {
+				fmt.Printf("Would remove: %s\n", cfgName)
+			} else if err := os.Remove(cfgPath); err != nil && !os.IsNotExist(err) {
+				fmt.Fprintf(os.Stderr, "Warning: failed to remove %s: %v\n", cfgName, err)
+			} else if err == nil {
+				fmt.Printf("Removed: %s\n", cfgName)
+			}
+
 			if !dryRun {
-				if err := os.Remove(configFile); err != nil && !os.IsNotExist(err) {
-					fmt.Fprintf(os.Stderr, "Warning: failed to remove %s: %v\n", configFile, err)
-				} else if err == nil {
-					fmt.Printf("Removed: %s\n", configFile)
-				}
-				if err := os.RemoveAll(githooksDir); err != nil {
+				if err := os.RemoveAll(scriptsDir); err != nil {
 					fmt.Fprintf(os.Stderr, "Warning: failed to remove %s: %v\n", githooksDir, err)
 				} else {
 					fmt.Printf("Removed: %s/\n", githooksDir)
 				}
 			} else {
-				fmt.Printf("Would remove: %s\n", configFile)
 				fmt.Printf("Would remove: %s/\n", githooksDir)
 			}
 		} else {
-			if _, err := os.Stat(configFile); err == nil {
-				fmt.Printf("Preserved: %s (use --remove-config to remove)\n", configFile)
+			if _, err := os.Stat(cfgPath); err == nil {
+				fmt.Printf("Preserved: %s (use --remove-config to remove)\n", cfgName)
 			}
-			if _, err := os.Stat(githooksDir); err == nil {
+			if _, err := os.Stat(scriptsDir); err == nil {
 				fmt.Printf("Preserved: %s/ (use --remove-config to remove)\n", githooksDir)
 			}
 		}
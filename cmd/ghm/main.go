@@ -30,9 +30,7 @@ func main() {
 
 	if exeName != "main" && exeName != "ghm" {
 		// Running as a hook
-		args := []string{"run", exeName}
-		args = append(args, os.Args[1:]...)
-		cmd.ExecuteWithArgs(args)
+		cmd.ExecuteHook(exeName, os.Args[1:])
 	} else {
 		// Running as ghm
 		cmd.Execute()
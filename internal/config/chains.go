@@ -0,0 +1,140 @@
+package config
+
+import (
+	"fmt"
+	"reflect"
+	"strings"
+)
+
+// IsChainRef reports whether the entry refers to a chain instead of
+// defining a command.
+func (hc HookCommand) IsChainRef() bool {
+	return hc.Chain != ""
+}
+
+// expandChains replaces the chain references in a hook's command list with
+// the commands of the chains, recursively. A reference's timeout replaces
+// the timeout of every command it expands to, and its env is merged over
+// theirs. For each command, refs describes where it was defined, such as
+// `command #2 (chain "lint" command #1)`.
+func (c *Config) expandChains(hookName string, commands []HookCommand) ([]HookCommand, []string, []error) {
+	var expanded []HookCommand
+	var refs []string
+	var errs []error
+
+	var expand func(list []HookCommand, path []string, stack []string)
+	expand = func(list []HookCommand, path []string, stack []string) {
+		for i, cmd := range list {
+			var p []string
+			if len(path) == 0 {
+				p = []string{fmt.Sprintf("command #%d", i+1)}
+			} else {
+				p = append(append([]string(nil), path...), fmt.Sprintf("chain %q command #%d", stack[len(stack)-1], i+1))
+			}
+			ref := formatRef(p)
+
+			if !cmd.IsChainRef() {
+				expanded = append(expanded, cmd)
+				refs = append(refs, ref)
+				continue
+			}
+
+			if err := validateChainRef(cmd); err != nil {
+				errs = append(errs, cmd.errorAt("chain", fmt.Errorf("hook %q %s: %w", hookName, ref, err)))
+				continue
+			}
+			chain, ok := c.Chains[cmd.Chain]
+			if !ok {
+				errs = append(errs, cmd.errorAt("chain", fmt.Errorf("hook %q %s: unknown chain %q", hookName, ref, cmd.Chain)))
+				continue
+			}
+			if i := indexOf(stack, cmd.Chain); i >= 0 {
+				cycle := append(append([]string(nil), stack[i:]...), cmd.Chain)
+				errs = append(errs, cmd.errorAt("chain", fmt.Errorf("hook %q %s: chain cycle: %s", hookName, ref, strings.Join(cycle, " -> "))))
+				continue
+			}
+
+			start := len(expanded)
+			expand(chain, p, append(stack, cmd.Chain))
+			for k := start; k < len(expanded); k++ {
+				applyChainOverrides(&expanded[k], cmd)
+			}
+		}
+	}
+
+	expand(commands, nil, nil)
+	return expanded, refs, errs
+}
+
+// ExpandChain returns the commands of a chain with nested chain references
+// expanded.
+func (c *Config) ExpandChain(name string) ([]HookCommand, error) {
+	if _, ok := c.Chains[name]; !ok {
+		return nil, fmt.Errorf("unknown chain %q", name)
+	}
+	expanded, _, errs := c.expandChains("", c.Chains[name])
+	if len(errs) > 0 {
+		return nil, errs[0]
+	}
+	return expanded, nil
+}
+
+// validateChainRef checks that a chain reference sets nothing besides the
+// chain name and the fields it may override.
+func validateChainRef(ref HookCommand) error {
+	rest := ref
+	rest.Chain, rest.Timeout, rest.Env, rest.origins = "", "", nil, nil
+	if !reflect.DeepEqual(rest, HookCommand{}) {
+		return fmt.Errorf("chain reference %q can only set 'timeout' and 'env'", ref.Chain)
+	}
+	return nil
+}
+
+// applyChainOverrides applies a chain reference's timeout and env to a
+// command from the chain.
+func applyChainOverrides(cmd *HookCommand, ref HookCommand) {
+	origins := make(map[string]Position, len(cmd.origins))
+	for k, v := range cmd.origins {
+		origins[k] = v
+	}
+	defer func() { cmd.origins = origins }()
+
+	if ref.Timeout != "" {
+		cmd.Timeout = ref.Timeout
+		if pos, ok := ref.origins["timeout"]; ok {
+			origins["timeout"] = pos
+		}
+	}
+	if len(ref.Env) > 0 {
+		env := make(map[string]string, len(cmd.Env)+len(ref.Env))
+		for k, v := range cmd.Env {
+			env[k] = v
+		}
+		for k, v := range ref.Env {
+			env[k] = v
+			if pos, ok := ref.origins["env."+k]; ok {
+				origins["env."+k] = pos
+			}
+		}
+		cmd.Env = env
+	}
+}
+
+// formatRef describes a command's position: the hook entry, followed by
+// the chain entries it was expanded through.
+func formatRef(path []string) string {
+	if len(path) == 1 {
+		return path[0]
+	}
+	return fmt.Sprintf("%s (%s)", path[0], strings.Join(path[1:], " > "))
+}
+
+// indexOf returns the position of s in list, or -1.
+func indexOf(list []string, s string) int {
+	for i, v := range list {
+		if v == s {
+			return i
+		}
+	}
+	return -1
+}
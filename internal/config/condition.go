@@ -0,0 +1,110 @@
+package config
+
+import (
+	"fmt"
+	"path"
+	"regexp"
+	"slices"
+	"strings"
+)
+
+// When holds the conditions under which a command runs. Every condition
+// that is set must hold for the command to run. The same block under
+// except skips the command when every condition in it holds.
+type When struct {
+	Branch       StringList `yaml:"branch,omitempty"`
+	Env          StringList `yaml:"env,omitempty"`
+	ChangedPaths StringList `yaml:"changed_paths,omitempty"`
+	OS           StringList `yaml:"os,omitempty"`
+	Shell        string     `yaml:"shell,omitempty"`
+}
+
+// Condition is the resolved form of a When block.
+type Condition struct {
+	Branches     []string    // glob patterns matched against the branch name
+	Env          []EnvMatch  // environment variables that must be set
+	ChangedPaths *FileFilter // nil if no changed_paths condition
+	OS           []string    // operating systems, as named by GOOS
+	Shell        string      // command that must exit with status 0
+}
+
+// EnvMatch requires an environment variable to be set and non-empty, or to
+// equal Value when HasValue is true.
+type EnvMatch struct {
+	Name     string
+	Value    string
+	HasValue bool
+}
+
+// String returns the condition as written in the config.
+func (m EnvMatch) String() string {
+	if m.HasValue {
+		return m.Name + "=" + m.Value
+	}
+	return m.Name
+}
+
+// envNamePattern matches valid environment variable names.
+var envNamePattern = regexp.MustCompile(`^[A-Za-z_][A-Za-z0-9_]*$`)
+
+// knownOS are the operating systems when.os accepts, as named by GOOS.
+var knownOS = []string{"aix", "android", "darwin", "dragonfly", "freebsd", "illumos", "ios", "linux", "netbsd", "openbsd", "plan9", "solaris", "windows"}
+
+// changedPathsHooks are the hooks that know which files changed: the
+// staged files for pre-commit and the pushed commits' files for pre-push.
+var changedPathsHooks = []string{"pre-commit", "pre-push"}
+
+// resolveWhen validates a When block for a command of the hook and
+// converts it to a Condition. key is the block's key, when or except, for
+// messages. Returns nil when no condition is set.
+func resolveWhen(hookName, key string, w *When) (*Condition, []error) {
+	if w == nil {
+		return nil, nil
+	}
+
+	var errs []error
+	cond := &Condition{Shell: strings.TrimSpace(w.Shell)}
+
+	for _, b := range w.Branch {
+		if _, err := path.Match(b, ""); err != nil {
+			errs = append(errs, fmt.Errorf("invalid %s.branch pattern %q: %w", key, b, err))
+			continue
+		}
+		cond.Branches = append(cond.Branches, b)
+	}
+
+	for _, e := range w.Env {
+		name, value, hasValue := strings.Cut(e, "=")
+		if !envNamePattern.MatchString(name) {
+			errs = append(errs, fmt.Errorf("invalid %s.env entry %q: expected NAME or NAME=value", key, e))
+			continue
+		}
+		cond.Env = append(cond.Env, EnvMatch{Name: name, Value: value, HasValue: hasValue})
+	}
+
+	if len(w.ChangedPaths) > 0 && !slices.Contains(changedPathsHooks, hookName) {
+		errs = append(errs, fmt.Errorf("%s.changed_paths is only supported on %s hooks", key, strings.Join(changedPathsHooks, " and ")))
+	} else if len(w.ChangedPaths) > 0 {
+		filter, filterErrs := NewFileFilter(w.ChangedPaths, nil, nil)
+		for _, err := range filterErrs {
+			errs = append(errs, fmt.Errorf("%s.changed_paths: %w", key, err))
+		}
+		cond.ChangedPaths = filter
+	}
+
+	for _, name := range w.OS {
+		if !slices.Contains(knownOS, name) {
+			errs = append(errs, fmt.Errorf("invalid %s.os %q: use an operating system as Go names it, such as linux, darwin or windows", key, name))
+			continue
+		}
+		cond.OS = append(cond.OS, name)
+	}
+
+	if len(errs) > 0 {
+		return nil, errs
+	}
+	if len(cond.Branches) == 0 && len(cond.Env) == 0 && cond.ChangedPaths == nil && len(cond.OS) == 0 && cond.Shell == "" {
+		return nil, nil
+	}
+	return cond, nil
+}
@@ -3,6 +3,9 @@ package config
 import (
 	"fmt"
 	"os"
+	"path/filepath"
+	"reflect"
+	"runtime"
 	"strings"
 	"time"
 
@@ -19,23 +22,95 @@ const (
 	LogError
 )
 
+// String returns the configuration spelling of the level.
+func (l LogLevel) String() string {
+	switch l {
+	case LogDebug:
+		return "debug"
+	case LogInfo:
+		return "info"
+	case LogWarn:
+		return "warn"
+	default:
+		return "error"
+	}
+}
+
+// OnFailure controls what happens to the rest of a hook when a command fails.
+type OnFailure int
+
+const (
+	OnFailureAbort    OnFailure = iota // stop the hook and fail it
+	OnFailureContinue                  // run the remaining commands, then fail the hook
+	OnFailureWarn                      // report the failure as a warning; never fail the hook
+)
+
+// String returns the configuration spelling of the policy.
+func (f OnFailure) String() string {
+	switch f {
+	case OnFailureContinue:
+		return "continue"
+	case OnFailureWarn:
+		return "warn"
+	default:
+		return "abort"
+	}
+}
+
 // DefaultTimeout is the timeout applied when no timeout is specified at any level.
 const DefaultTimeout = 30 * time.Second
 
+// DefaultKillGrace is how long a timed-out command is given to exit after
+// SIGTERM before it is killed, when no kill_grace is specified at any level.
+const DefaultKillGrace = 5 * time.Second
+
 // Config represents the main configuration structure from .githooksrc.yml
 type Config struct {
-	Timeout  string                   `yaml:"timeout"`
-	LogLevel string                   `yaml:"log_level"`
-	Hooks    map[string][]HookCommand `yaml:"hooks"`
+	Timeout       string                   `yaml:"timeout"`
+	LogLevel      string                   `yaml:"log_level"`
+	KillGrace     string                   `yaml:"kill_grace,omitempty"`
+	MaxParallel   int                      `yaml:"max_parallel,omitempty"`
+	StashUnstaged bool                     `yaml:"stash_unstaged,omitempty"`
+	Hooks         map[string][]HookCommand `yaml:"hooks"`
+	Chains        map[string][]HookCommand `yaml:"chains,omitempty"`
+	Merge         map[string]string        `yaml:"merge,omitempty"` // hook name to merge strategy over lower layers
+	Extends       Extends                  `yaml:"extends,omitempty"`
+
+	dir     string              // directory of the file the config was loaded from
+	origins map[string]Position // where each top-level key was set; see Origin
+	errs    []error             // problems found while loading, reported by Resolve
 }
 
 // HookCommand represents a single command to be executed for a hook.
 type HookCommand struct {
-	Run         string `yaml:"run"`
-	Description string `yaml:"description"`
-	Enabled     *bool  `yaml:"enabled,omitempty"`
-	Timeout     string `yaml:"timeout,omitempty"`
-	LogLevel    string `yaml:"log_level,omitempty"`
+	ID             string            `yaml:"id,omitempty"`
+	Chain          string            `yaml:"chain,omitempty"`
+	Run            string            `yaml:"run"`
+	Script         string            `yaml:"script,omitempty"`
+	Shell          StringList        `yaml:"shell,omitempty"`
+	Description    string            `yaml:"description"`
+	Enabled        *bool             `yaml:"enabled,omitempty"`
+	Timeout        string            `yaml:"timeout,omitempty"`
+	KillGrace      string            `yaml:"kill_grace,omitempty"`
+	LogLevel       string            `yaml:"log_level,omitempty"`
+	Parallel       bool              `yaml:"parallel,omitempty"`
+	Glob           StringList        `yaml:"glob,omitempty"`
+	Exclude        StringList        `yaml:"exclude,omitempty"`
+	Types          StringList        `yaml:"types,omitempty"`
+	StageFixed     bool              `yaml:"stage_fixed,omitempty"`
+	When           *When             `yaml:"when,omitempty"`
+	Except         *When             `yaml:"except,omitempty"`
+	OnFailure      string            `yaml:"on_failure,omitempty"`
+	Retries        int               `yaml:"retries,omitempty"`
+	RetryDelay     string            `yaml:"retry_delay,omitempty"`
+	RetryBackoff   float64           `yaml:"retry_backoff,omitempty"`
+	RetryOnTimeout bool              `yaml:"retry_on_timeout,omitempty"`
+	Env            map[string]string `yaml:"env,omitempty"`
+	EnvFile        string            `yaml:"env_file,omitempty"`
+	Dir            string            `yaml:"dir,omitempty"`
+	Needs          StringList        `yaml:"needs,omitempty"`
+
+	origins map[string]Position // where each field was set, and the command itself under ""
 }
 
 // IsEnabled returns true if the command is enabled (nil defaults to true).
@@ -46,6 +121,18 @@ func (hc HookCommand) IsEnabled() bool {
 	return *hc.Enabled
 }
 
+// Label returns the run string of the command, the path of its script
+// relative to the repository root, or "chain <name>" for a chain reference.
+func (hc HookCommand) Label() string {
+	if hc.Chain != "" {
+		return "chain " + hc.Chain
+	}
+	if hc.Script != "" {
+		return ScriptsDir + "/" + hc.Script
+	}
+	return hc.Run
+}
+
 // BoolPtr returns a pointer to the given bool value.
 func BoolPtr(b bool) *bool {
 	return &b
@@ -53,18 +140,54 @@ func BoolPtr(b bool) *bool {
 
 // ResolvedConfig holds validated, runtime-ready configuration.
 type ResolvedConfig struct {
-	Timeout  time.Duration
-	LogLevel LogLevel
-	Hooks    map[string][]ResolvedHookCommand
+	Timeout       time.Duration
+	KillGrace     time.Duration
+	LogLevel      LogLevel
+	MaxParallel   int
+	StashUnstaged bool // pre-commit runs against the index only
+	Hooks         map[string][]ResolvedHookCommand
 }
 
 // ResolvedHookCommand holds a fully resolved command ready for execution.
 type ResolvedHookCommand struct {
-	Run         string
-	Description string
-	Timeout     time.Duration // 0 means no timeout (only via "none")
-	LogLevel    LogLevel
-	Enabled     bool
+	ID             string
+	Run            string   // shell script, or the script path for script commands
+	Shell          Shell    // interpreter for Run
+	Script         string   // absolute path of the script file; empty unless script is set
+	Interpreter    []string // interpreter from the script's #! line; nil runs it with sh
+	Description    string
+	Timeout        time.Duration // 0 means no timeout (only via "none")
+	KillGrace      time.Duration // wait between SIGTERM and SIGKILL on timeout; 0 kills at once
+	LogLevel       LogLevel
+	Enabled        bool
+	Parallel       bool        // may run concurrently with adjacent parallel commands
+	Files          *FileFilter // nil means the command is not filtered by file
+	StageFixed     bool        // re-stage staged files the command modifies
+	When           *Condition  // nil means the command always runs
+	Except         *Condition  // skips the command when it holds; nil if none
+	OnFailure      OnFailure
+	Retries        int           // additional attempts after a failure
+	RetryDelay     time.Duration // wait before the first retry
+	RetryBackoff   float64       // factor applied to the delay after each retry; 1 means constant
+	RetryOnTimeout bool          // also retry attempts that timed out
+	Env            []EnvVar      // sorted by name; expanded when the command runs
+	EnvFile        string        // dotenv file relative to the repo root; empty if none
+	Dir            string        // working directory relative to the repo root; empty means the root
+	Needs          []string      // ids of commands that must succeed before this one starts
+}
+
+// Matches reports whether a selector, as used by GHM_SKIP and the --skip
+// and --only flags, refers to this command by its id or its run string.
+func (rc ResolvedHookCommand) Matches(selector string) bool {
+	return selector == rc.Run || (rc.ID != "" && selector == rc.ID)
+}
+
+// Name returns the id of the command if it has one, or its run string.
+func (rc ResolvedHookCommand) Name() string {
+	if rc.ID != "" {
+		return rc.ID
+	}
+	return rc.Run
 }
 
 // Load reads the configuration file from the given path and returns a Config struct.
@@ -74,59 +197,109 @@ func Load(path string) (*Config, error) {
 		return nil, fmt.Errorf("failed to read config file: %w", err)
 	}
 
+	cfg, err := parse(data, path)
+	if err != nil {
+		return nil, err
+	}
+	cfg.dir = filepath.Dir(path)
+
+	return cfg, nil
+}
+
+// parse decodes the YAML of a config file named file. Unknown keys are
+// recorded as validation errors for Resolve, and the position of every
+// value is recorded for locating errors.
+func parse(data []byte, file string) (*Config, error) {
+	var root yaml.Node
+	if err := yaml.Unmarshal(data, &root); err != nil {
+		return nil, fmt.Errorf("failed to parse config file: %w", err)
+	}
 	var cfg Config
-	if err := yaml.Unmarshal(data, &cfg); err != nil {
+	if len(root.Content) == 0 {
+		return &cfg, nil // empty file
+	}
+	if err := root.Decode(&cfg); err != nil {
 		return nil, fmt.Errorf("failed to parse config file: %w", err)
 	}
 
+	lines := strings.Split(string(data), "\n")
+	cfg.errs = checkKeys(root.Content[0], reflect.TypeOf(cfg), file, lines)
+	cfg.recordPositions(&root, file, lines)
 	return &cfg, nil
 }
 
-// Save writes a Config to the given file path as YAML.
+// Save writes a Config to the given file path as YAML, replacing the file
+// atomically. The whole file is rewritten, dropping comments; use
+// OpenDocument to edit an existing file.
 func Save(path string, cfg *Config) error {
 	data, err := yaml.Marshal(cfg)
 	if err != nil {
 		return fmt.Errorf("failed to marshal config: %w", err)
 	}
 
-	if err := os.WriteFile(path, data, 0644); err != nil {
+	if err := writeFileAtomic(path, data); err != nil {
 		return fmt.Errorf("failed to write config file: %w", err)
 	}
-
 	return nil
 }
 
 // Resolve validates and resolves a raw Config into a ResolvedConfig.
 // Returns ALL validation errors, not just the first.
 func (c *Config) Resolve() (*ResolvedConfig, []error) {
-	var errs []error
+	errs := append([]error(nil), c.errs...)
 
 	// Resolve global timeout
 	globalTimeout := DefaultTimeout
 	if c.Timeout != "" {
 		d, err := time.ParseDuration(c.Timeout)
 		if err != nil {
-			errs = append(errs, fmt.Errorf("invalid global timeout %q: %w", c.Timeout, err))
+			errs = append(errs, c.errorAt("timeout", fmt.Errorf("invalid global timeout %q: %w", c.Timeout, err)))
 		} else if d == 0 {
-			errs = append(errs, fmt.Errorf("invalid global timeout \"0s\": use \"none\" on individual commands to disable timeout"))
+			errs = append(errs, c.errorAt("timeout", fmt.Errorf("invalid global timeout \"0s\": use \"none\" on individual commands to disable timeout")))
 		} else if d < 0 {
-			errs = append(errs, fmt.Errorf("invalid global timeout %q: must be positive", c.Timeout))
+			errs = append(errs, c.errorAt("timeout", fmt.Errorf("invalid global timeout %q: must be positive", c.Timeout)))
 		} else {
 			globalTimeout = d
 		}
 	}
 
+	// Resolve global kill grace period
+	globalKillGrace := DefaultKillGrace
+	if c.KillGrace != "" {
+		d, err := parseKillGrace(c.KillGrace)
+		if err != nil {
+			errs = append(errs, c.errorAt("kill_grace", fmt.Errorf("invalid global kill_grace %q: %w", c.KillGrace, err)))
+		} else {
+			globalKillGrace = d
+		}
+	}
+
 	// Resolve global log level
 	globalLogLevel := LogWarn
 	if c.LogLevel != "" {
 		ll, err := parseLogLevel(c.LogLevel)
 		if err != nil {
-			errs = append(errs, fmt.Errorf("invalid global log_level %q: valid levels are debug, info, warn, error", c.LogLevel))
+			errs = append(errs, c.errorAt("log_level", fmt.Errorf("invalid global log_level %q: valid levels are debug, info, warn, error", c.LogLevel)))
 		} else {
 			globalLogLevel = ll
 		}
 	}
 
+	// Resolve parallelism limit
+	maxParallel := runtime.NumCPU()
+	if c.MaxParallel < 0 {
+		errs = append(errs, c.errorAt("max_parallel", fmt.Errorf("invalid max_parallel %d: must be positive", c.MaxParallel)))
+	} else if c.MaxParallel > 0 {
+		maxParallel = c.MaxParallel
+	}
+
+	// Validate chain names
+	for name := range c.Chains {
+		if !commandIDPattern.MatchString(name) {
+			errs = append(errs, c.errorAt("chains."+name, fmt.Errorf("invalid chain name %q: must start with a letter or digit and contain only letters, digits, '.', '_' or '-'", name)))
+		}
+	}
+
 	// Resolve hooks
 	resolvedHooks := make(map[string][]ResolvedHookCommand)
 
@@ -138,15 +311,62 @@ func (c *Config) Resolve() (*ResolvedConfig, []error) {
 			if suggestion != "" {
 				msg += fmt.Sprintf("; did you mean %q?", suggestion)
 			}
-			errs = append(errs, fmt.Errorf("%s", msg))
+			errs = append(errs, c.errorAt("hooks."+hookName, fmt.Errorf("%s", msg)))
 			continue
 		}
 
+		// Expand chain references
+		commands, refs, chainErrs := c.expandChains(hookName, commands)
+		errs = append(errs, chainErrs...)
+
 		var resolved []ResolvedHookCommand
+		seenIDs := make(map[string]int)
 		for i, cmd := range commands {
-			// Validate run field
-			if strings.TrimSpace(cmd.Run) == "" {
-				errs = append(errs, fmt.Errorf("hook %q command #%d: 'run' field is required but missing or empty", hookName, i+1))
+			// Validate id
+			if cmd.ID != "" {
+				if err := ValidateCommandID(cmd.ID); err != nil {
+					errs = append(errs, cmd.errorAt("id", fmt.Errorf("hook %q %s: %w", hookName, refs[i], err)))
+					continue
+				}
+				if first, ok := seenIDs[cmd.ID]; ok {
+					errs = append(errs, cmd.errorAt("id", fmt.Errorf("hook %q %s: duplicate id %q (already used by %s)", hookName, refs[i], cmd.ID, refs[first])))
+					continue
+				}
+				seenIDs[cmd.ID] = i
+			}
+
+			// Validate run or script field
+			run := cmd.Run
+			var script string
+			var interpreter []string
+			if cmd.Script != "" {
+				if strings.TrimSpace(cmd.Run) != "" {
+					errs = append(errs, cmd.errorAt("script", fmt.Errorf("hook %q %s: 'run' and 'script' cannot both be set", hookName, refs[i])))
+					continue
+				}
+				if len(cmd.Shell) > 0 {
+					errs = append(errs, cmd.errorAt("shell", fmt.Errorf("hook %q %s: 'shell' cannot be used with 'script'; the script's #! line picks the interpreter", hookName, refs[i])))
+					continue
+				}
+				var err error
+				run, script, interpreter, err = resolveScript(c.dir, cmd.Script)
+				if err != nil {
+					errs = append(errs, cmd.errorAt("script", fmt.Errorf("hook %q %s: %w", hookName, refs[i], err)))
+					continue
+				}
+			} else if strings.TrimSpace(cmd.Run) == "" {
+				errs = append(errs, cmd.errorAt("run", fmt.Errorf("hook %q %s: 'run' field is required but missing or empty", hookName, refs[i])))
+				continue
+			}
+
+			// Resolve shell
+			shell, err := resolveShell(cmd.Shell)
+			if err != nil {
+				errs = append(errs, cmd.errorAt("shell", fmt.Errorf("hook %q %s: %w", hookName, refs[i], err)))
+				continue
+			}
+			if script == "" && shell.Quoting() == QuoteUnknown && usesPlaceholders(run) {
+				errs = append(errs, cmd.errorAt("run", fmt.Errorf("hook %q %s: placeholders such as {staged_files} cannot be quoted for shell %q; use sh, bash, zsh or pwsh, or read GHM_STAGED_FILES", hookName, refs[i], strings.Join(shell.Argv, " "))))
 				continue
 			}
 
@@ -158,41 +378,159 @@ func (c *Config) Resolve() (*ResolvedConfig, []error) {
 				} else {
 					d, err := time.ParseDuration(cmd.Timeout)
 					if err != nil {
-						errs = append(errs, fmt.Errorf("hook %q command #%d: invalid timeout %q: %w", hookName, i+1, cmd.Timeout, err))
+						errs = append(errs, cmd.errorAt("timeout", fmt.Errorf("hook %q %s: invalid timeout %q: %w", hookName, refs[i], cmd.Timeout, err)))
 						continue
 					}
 					if d == 0 {
-						errs = append(errs, fmt.Errorf("hook %q command #%d: invalid timeout \"0s\": use \"none\" to disable timeout", hookName, i+1))
+						errs = append(errs, cmd.errorAt("timeout", fmt.Errorf("hook %q %s: invalid timeout \"0s\": use \"none\" to disable timeout", hookName, refs[i])))
 						continue
 					}
 					if d < 0 {
-						errs = append(errs, fmt.Errorf("hook %q command #%d: invalid timeout %q: must be positive", hookName, i+1, cmd.Timeout))
+						errs = append(errs, cmd.errorAt("timeout", fmt.Errorf("hook %q %s: invalid timeout %q: must be positive", hookName, refs[i], cmd.Timeout)))
 						continue
 					}
 					cmdTimeout = d
 				}
 			}
 
+			// Resolve kill grace period
+			cmdKillGrace := globalKillGrace
+			if cmd.KillGrace != "" {
+				d, err := parseKillGrace(cmd.KillGrace)
+				if err != nil {
+					errs = append(errs, cmd.errorAt("kill_grace", fmt.Errorf("hook %q %s: invalid kill_grace %q: %w", hookName, refs[i], cmd.KillGrace, err)))
+					continue
+				}
+				cmdKillGrace = d
+			}
+
+			// Resolve environment and working directory
+			var envErrs []error
+			env, nameErrs := resolveEnv(cmd.Env)
+			for _, err := range nameErrs {
+				envErrs = append(envErrs, cmd.errorAt("env", fmt.Errorf("hook %q %s: %w", hookName, refs[i], err)))
+			}
+			if cmd.EnvFile != "" {
+				if err := validateRepoPath("env_file", cmd.EnvFile); err != nil {
+					envErrs = append(envErrs, cmd.errorAt("env_file", fmt.Errorf("hook %q %s: %w", hookName, refs[i], err)))
+				}
+			}
+			if cmd.Dir != "" {
+				if err := validateRepoPath("dir", cmd.Dir); err != nil {
+					envErrs = append(envErrs, cmd.errorAt("dir", fmt.Errorf("hook %q %s: %w", hookName, refs[i], err)))
+				}
+			}
+			if len(envErrs) > 0 {
+				errs = append(errs, envErrs...)
+				continue
+			}
+
+			// Resolve retry policy
+			if cmd.Retries < 0 {
+				errs = append(errs, cmd.errorAt("retries", fmt.Errorf("hook %q %s: invalid retries %d: must not be negative", hookName, refs[i], cmd.Retries)))
+				continue
+			}
+			var retryDelay time.Duration
+			if cmd.RetryDelay != "" {
+				d, err := time.ParseDuration(cmd.RetryDelay)
+				if err != nil {
+					errs = append(errs, cmd.errorAt("retry_delay", fmt.Errorf("hook %q %s: invalid retry_delay %q: %w", hookName, refs[i], cmd.RetryDelay, err)))
+					continue
+				}
+				if d < 0 {
+					errs = append(errs, cmd.errorAt("retry_delay", fmt.Errorf("hook %q %s: invalid retry_delay %q: must not be negative", hookName, refs[i], cmd.RetryDelay)))
+					continue
+				}
+				retryDelay = d
+			}
+			retryBackoff := 1.0
+			if cmd.RetryBackoff != 0 {
+				if cmd.RetryBackoff < 1 {
+					errs = append(errs, cmd.errorAt("retry_backoff", fmt.Errorf("hook %q %s: invalid retry_backoff %g: must be at least 1", hookName, refs[i], cmd.RetryBackoff)))
+					continue
+				}
+				retryBackoff = cmd.RetryBackoff
+			}
+
 			// Resolve log level
 			cmdLogLevel := globalLogLevel
 			if cmd.LogLevel != "" {
 				ll, err := parseLogLevel(cmd.LogLevel)
 				if err != nil {
-					errs = append(errs, fmt.Errorf("hook %q command #%d: invalid log_level %q: valid levels are debug, info, warn, error", hookName, i+1, cmd.LogLevel))
+					errs = append(errs, cmd.errorAt("log_level", fmt.Errorf("hook %q %s: invalid log_level %q: valid levels are debug, info, warn, error", hookName, refs[i], cmd.LogLevel)))
 					continue
 				}
 				cmdLogLevel = ll
 			}
 
+			// Resolve failure policy
+			onFailure := OnFailureAbort
+			if cmd.OnFailure != "" {
+				f, err := parseOnFailure(cmd.OnFailure)
+				if err != nil {
+					errs = append(errs, cmd.errorAt("on_failure", fmt.Errorf("hook %q %s: invalid on_failure %q: valid values are abort, continue, warn", hookName, refs[i], cmd.OnFailure)))
+					continue
+				}
+				onFailure = f
+			}
+
+			// Resolve file filter
+			var files *FileFilter
+			if len(cmd.Glob) > 0 || len(cmd.Exclude) > 0 || len(cmd.Types) > 0 {
+				filter, filterErrs := NewFileFilter(cmd.Glob, cmd.Exclude, cmd.Types)
+				if len(filterErrs) > 0 {
+					for _, err := range filterErrs {
+						errs = append(errs, cmd.errorAt(filterKey(err), fmt.Errorf("hook %q %s: %w", hookName, refs[i], err)))
+					}
+					continue
+				}
+				files = filter
+			}
+
+			// Resolve conditions
+			when, whenErrs := resolveWhen(hookName, "when", cmd.When)
+			except, exceptErrs := resolveWhen(hookName, "except", cmd.Except)
+			for _, err := range whenErrs {
+				errs = append(errs, cmd.errorAt("when", fmt.Errorf("hook %q %s: %w", hookName, refs[i], err)))
+			}
+			for _, err := range exceptErrs {
+				errs = append(errs, cmd.errorAt("except", fmt.Errorf("hook %q %s: %w", hookName, refs[i], err)))
+			}
+			if len(whenErrs) > 0 || len(exceptErrs) > 0 {
+				continue
+			}
+
 			resolved = append(resolved, ResolvedHookCommand{
-				Run:         cmd.Run,
-				Description: cmd.Description,
-				Timeout:     cmdTimeout,
-				LogLevel:    cmdLogLevel,
-				Enabled:     cmd.IsEnabled(),
+				ID:             cmd.ID,
+				Run:            run,
+				Shell:          shell,
+				Script:         script,
+				Interpreter:    interpreter,
+				Description:    cmd.Description,
+				Timeout:        cmdTimeout,
+				KillGrace:      cmdKillGrace,
+				LogLevel:       cmdLogLevel,
+				Enabled:        cmd.IsEnabled(),
+				Parallel:       cmd.Parallel,
+				Files:          files,
+				StageFixed:     cmd.StageFixed,
+				When:           when,
+				Except:         except,
+				OnFailure:      onFailure,
+				Retries:        cmd.Retries,
+				RetryDelay:     retryDelay,
+				RetryBackoff:   retryBackoff,
+				RetryOnTimeout: cmd.RetryOnTimeout,
+				Env:            env,
+				EnvFile:        cleanRepoPath(cmd.EnvFile),
+				Dir:            cleanRepoPath(cmd.Dir),
+				Needs:          cmd.Needs,
 			})
 		}
 
+		// Validate dependencies between commands
+		errs = append(errs, validateNeeds(hookName, commands, refs)...)
+
 		if len(resolved) > 0 {
 			resolvedHooks[hookName] = resolved
 		}
@@ -203,12 +541,27 @@ func (c *Config) Resolve() (*ResolvedConfig, []error) {
 	}
 
 	return &ResolvedConfig{
-		Timeout:  globalTimeout,
-		LogLevel: globalLogLevel,
-		Hooks:    resolvedHooks,
+		Timeout:       globalTimeout,
+		KillGrace:     globalKillGrace,
+		LogLevel:      globalLogLevel,
+		MaxParallel:   maxParallel,
+		StashUnstaged: c.StashUnstaged,
+		Hooks:         resolvedHooks,
 	}, nil
 }
 
+// filterKey returns the field a NewFileFilter error is about.
+func filterKey(err error) string {
+	switch msg := err.Error(); {
+	case strings.HasPrefix(msg, "invalid exclude"):
+		return "exclude"
+	case strings.HasPrefix(msg, "invalid glob"):
+		return "glob"
+	default:
+		return "types"
+	}
+}
+
 // parseLogLevel converts a string log level to the LogLevel type.
 func parseLogLevel(s string) (LogLevel, error) {
 	switch strings.ToLower(s) {
@@ -224,3 +577,29 @@ func parseLogLevel(s string) (LogLevel, error) {
 		return LogWarn, fmt.Errorf("unknown log level: %s", s)
 	}
 }
+
+// parseOnFailure converts a string failure policy to the OnFailure type.
+func parseOnFailure(s string) (OnFailure, error) {
+	switch strings.ToLower(s) {
+	case "abort":
+		return OnFailureAbort, nil
+	case "continue":
+		return OnFailureContinue, nil
+	case "warn":
+		return OnFailureWarn, nil
+	default:
+		return OnFailureAbort, fmt.Errorf("unknown on_failure value: %s", s)
+	}
+}
+
+// parseKillGrace parses a kill_grace duration, which must not be negative.
+func parseKillGrace(s string) (time.Duration, error) {
+	d, err := time.ParseDuration(s)
+	if err != nil {
+		return 0, err
+	}
+	if d < 0 {
+		return 0, fmt.Errorf("must not be negative")
+	}
+	return d, nil
+}
@@ -1,8 +1,11 @@
 package config
 
 import (
+	"errors"
 	"os"
+	"os/exec"
 	"path/filepath"
+	"reflect"
 	"strings"
 	"testing"
 	"time"
@@ -272,6 +275,270 @@ func TestResolve_PerCommandOverrides(t *testing.T) {
 	}
 }
 
+func TestResolve_Parallel(t *testing.T) {
+	cfg := &Config{
+		MaxParallel: 2,
+		Hooks: map[string][]HookCommand{
+			"pre-commit": {
+				{Run: "npm run lint", Parallel: true},
+				{Run: "npm run test"},
+			},
+		},
+	}
+
+	resolved, errs := cfg.Resolve()
+	if len(errs) > 0 {
+		t.Fatalf("Resolve() errors = %v", errs)
+	}
+	if resolved.MaxParallel != 2 {
+		t.Errorf("MaxParallel = %d, want 2", resolved.MaxParallel)
+	}
+
+	cmds := resolved.Hooks["pre-commit"]
+	if !cmds[0].Parallel {
+		t.Error("first command should be parallel")
+	}
+	if cmds[1].Parallel {
+		t.Error("second command should not be parallel")
+	}
+}
+
+func TestResolve_MaxParallelDefault(t *testing.T) {
+	resolved, errs := (&Config{}).Resolve()
+	if len(errs) > 0 {
+		t.Fatalf("Resolve() errors = %v", errs)
+	}
+	if resolved.MaxParallel < 1 {
+		t.Errorf("MaxParallel = %d, want a positive default", resolved.MaxParallel)
+	}
+}
+
+func TestResolve_InvalidMaxParallel(t *testing.T) {
+	_, errs := (&Config{MaxParallel: -1}).Resolve()
+	if len(errs) == 0 {
+		t.Fatal("expected error for negative max_parallel")
+	}
+	if !strings.Contains(errs[0].Error(), "max_parallel") {
+		t.Errorf("error = %q, want it to mention max_parallel", errs[0])
+	}
+}
+
+func TestLoad_FileFilterFields(t *testing.T) {
+	yamlContent := `
+hooks:
+  pre-commit:
+    - run: "gofmt -l {staged_files}"
+      glob: "*.go"
+      exclude:
+        - "vendor/**"
+        - "regex:_gen\\.go$"
+      types: [go]
+`
+	tmpfile := writeTempFile(t, yamlContent)
+	defer os.Remove(tmpfile)
+
+	cfg, err := Load(tmpfile)
+	if err != nil {
+		t.Fatalf("Load() error = %v", err)
+	}
+
+	cmd := cfg.Hooks["pre-commit"][0]
+	if len(cmd.Glob) != 1 || cmd.Glob[0] != "*.go" {
+		t.Errorf("Glob = %v, want [*.go]", cmd.Glob)
+	}
+	if len(cmd.Exclude) != 2 {
+		t.Errorf("Exclude = %v, want 2 entries", cmd.Exclude)
+	}
+
+	resolved, errs := cfg.Resolve()
+	if len(errs) > 0 {
+		t.Fatalf("Resolve() errors = %v", errs)
+	}
+	files := resolved.Hooks["pre-commit"][0].Files
+	if files == nil {
+		t.Fatal("expected a file filter")
+	}
+	got := files.Filter([]string{"main.go", "vendor/a/b.go", "api_gen.go", "README.md", "cmd/x/y.go"})
+	if strings.Join(got, ",") != "main.go,cmd/x/y.go" {
+		t.Errorf("Filter() = %v, want [main.go cmd/x/y.go]", got)
+	}
+}
+
+func TestResolve_InvalidFileFilter(t *testing.T) {
+	cfg := &Config{
+		Hooks: map[string][]HookCommand{
+			"pre-commit": {
+				{Run: "lint", Glob: StringList{"[a-"}, Exclude: StringList{"regex:("}, Types: StringList{"pyton"}},
+			},
+		},
+	}
+
+	_, errs := cfg.Resolve()
+	if len(errs) != 3 {
+		t.Fatalf("expected 3 errors, got %d: %v", len(errs), errs)
+	}
+	if !strings.Contains(errs[2].Error(), `did you mean "python"`) {
+		t.Errorf("error = %q, want a suggestion for 'python'", errs[2])
+	}
+}
+
+func TestMatchGlob(t *testing.T) {
+	tests := []struct {
+		pattern string
+		name    string
+		want    bool
+	}{
+		{"*.go", "main.go", true},
+		{"*.go", "internal/x/main.go", true},
+		{"*.go", "main.go.orig", false},
+		{"cmd/*.go", "cmd/main.go", true},
+		{"cmd/*.go", "cmd/sub/main.go", false},
+		{"src/**/*.ts", "src/a.ts", true},
+		{"src/**/*.ts", "src/a/b/c.ts", true},
+		{"src/**/*.ts", "lib/a.ts", false},
+		{"**/testdata/**", "pkg/testdata/x.json", true},
+	}
+
+	for _, tt := range tests {
+		if got := MatchGlob(tt.pattern, tt.name); got != tt.want {
+			t.Errorf("MatchGlob(%q, %q) = %v, want %v", tt.pattern, tt.name, got, tt.want)
+		}
+	}
+}
+
+func TestResolve_CommandIDs(t *testing.T) {
+	cfg := &Config{
+		Hooks: map[string][]HookCommand{
+			"pre-commit": {
+				{ID: "lint", Run: "npm run lint"},
+				{ID: "lint", Run: "npm run lint -- --fix"},
+				{ID: "bad id", Run: "npm test"},
+			},
+			"pre-push": {
+				{ID: "lint", Run: "npm run lint"},
+			},
+		},
+	}
+
+	_, errs := cfg.Resolve()
+	if len(errs) != 2 {
+		t.Fatalf("expected 2 errors, got %d: %v", len(errs), errs)
+	}
+	joined := errs[0].Error() + "\n" + errs[1].Error()
+	if !strings.Contains(joined, `duplicate id "lint" (already used by command #1)`) {
+		t.Errorf("errors = %q, want a duplicate id error", joined)
+	}
+	if !strings.Contains(joined, `invalid id "bad id"`) {
+		t.Errorf("errors = %q, want an invalid id error", joined)
+	}
+}
+
+func TestResolvedHookCommand_Matches(t *testing.T) {
+	c := ResolvedHookCommand{ID: "lint", Run: "npm run lint"}
+	if !c.Matches("lint") || !c.Matches("npm run lint") {
+		t.Error("Matches() should accept the id and the run string")
+	}
+	if c.Matches("npm") {
+		t.Error("Matches() should not accept partial run strings")
+	}
+	if c.Name() != "lint" {
+		t.Errorf("Name() = %q, want %q", c.Name(), "lint")
+	}
+	if (ResolvedHookCommand{Run: "npm test"}).Name() != "npm test" {
+		t.Error("Name() should fall back to the run string")
+	}
+}
+
+func TestLoad_WhenBlock(t *testing.T) {
+	yamlContent := `
+hooks:
+  pre-push:
+    - run: "make test-all"
+      when:
+        branch: [main, "release/*"]
+        env: CI=true
+        changed_paths: "**/*.go"
+        os: [linux, darwin]
+        shell: "test -f go.mod"
+      except:
+        env: SKIP_SLOW
+`
+	tmpfile := writeTempFile(t, yamlContent)
+	defer os.Remove(tmpfile)
+
+	cfg, err := Load(tmpfile)
+	if err != nil {
+		t.Fatalf("Load() error = %v", err)
+	}
+	resolved, errs := cfg.Resolve()
+	if len(errs) > 0 {
+		t.Fatalf("Resolve() errors = %v", errs)
+	}
+
+	when := resolved.Hooks["pre-push"][0].When
+	if when == nil {
+		t.Fatal("expected a condition")
+	}
+	if len(when.Branches) != 2 || when.Branches[1] != "release/*" {
+		t.Errorf("Branches = %v, want [main release/*]", when.Branches)
+	}
+	if len(when.Env) != 1 || when.Env[0].Name != "CI" || when.Env[0].Value != "true" || !when.Env[0].HasValue {
+		t.Errorf("Env = %+v, want CI=true", when.Env)
+	}
+	if when.ChangedPaths == nil || !when.ChangedPaths.Match("cmd/main.go") {
+		t.Error("ChangedPaths should match cmd/main.go")
+	}
+	if len(when.OS) != 2 || when.OS[0] != "linux" || when.OS[1] != "darwin" {
+		t.Errorf("OS = %v, want [linux darwin]", when.OS)
+	}
+	if when.Shell != "test -f go.mod" {
+		t.Errorf("Shell = %q, want %q", when.Shell, "test -f go.mod")
+	}
+	except := resolved.Hooks["pre-push"][0].Except
+	if except == nil || len(except.Env) != 1 || except.Env[0].Name != "SKIP_SLOW" {
+		t.Errorf("Except = %+v, want env SKIP_SLOW", except)
+	}
+}
+
+func TestResolve_InvalidWhen(t *testing.T) {
+	cfg := &Config{
+		Hooks: map[string][]HookCommand{
+			"pre-push": {
+				{
+					Run:    "make",
+					When:   &When{Branch: StringList{"[main"}, Env: StringList{"1BAD"}, ChangedPaths: StringList{"regex:("}, OS: StringList{"macos"}},
+					Except: &When{Branch: StringList{"[dev"}},
+				},
+			},
+		},
+	}
+
+	_, errs := cfg.Resolve()
+	if len(errs) != 5 {
+		t.Fatalf("expected 5 errors, got %d: %v", len(errs), errs)
+	}
+	for i, want := range []string{"when.branch", "when.env", "when.changed_paths", `when.os "macos"`, "except.branch"} {
+		if !strings.Contains(errs[i].Error(), want) {
+			t.Errorf("error %d = %q, want it to mention %s", i, errs[i], want)
+		}
+	}
+}
+
+func TestResolve_ChangedPathsNeedsFileSet(t *testing.T) {
+	cfg := &Config{
+		Hooks: map[string][]HookCommand{
+			"commit-msg": {
+				{Run: "make", When: &When{ChangedPaths: StringList{"*.go"}}},
+			},
+		},
+	}
+
+	_, errs := cfg.Resolve()
+	if len(errs) != 1 || !strings.Contains(errs[0].Error(), "when.changed_paths is only supported on pre-commit and pre-push hooks") {
+		t.Errorf("errors = %v", errs)
+	}
+}
+
 func TestResolve_DisabledCommand(t *testing.T) {
 	cfg := &Config{
 		Hooks: map[string][]HookCommand{
@@ -377,79 +644,1139 @@ func TestResolve_InvalidLogLevel(t *testing.T) {
 	}
 }
 
-func TestResolve_MultipleErrors(t *testing.T) {
+func TestResolve_OnFailure(t *testing.T) {
 	cfg := &Config{
-		Timeout:  "banana",
-		LogLevel: "verbose",
 		Hooks: map[string][]HookCommand{
 			"pre-commit": {
-				{Description: "no run"},
+				{Run: "lint"},
+				{Run: "test", OnFailure: "continue"},
+				{Run: "todo-check", OnFailure: "WARN"},
+			},
+		},
+	}
+
+	resolved, errs := cfg.Resolve()
+	if len(errs) > 0 {
+		t.Fatalf("Resolve() errors = %v", errs)
+	}
+	want := []OnFailure{OnFailureAbort, OnFailureContinue, OnFailureWarn}
+	for i, cmd := range resolved.Hooks["pre-commit"] {
+		if cmd.OnFailure != want[i] {
+			t.Errorf("command #%d OnFailure = %s, want %s", i+1, cmd.OnFailure, want[i])
+		}
+	}
+}
+
+func TestResolve_InvalidOnFailure(t *testing.T) {
+	cfg := &Config{
+		Hooks: map[string][]HookCommand{
+			"pre-commit": {
+				{Run: "lint", OnFailure: "ignore"},
 			},
 		},
 	}
 
 	_, errs := cfg.Resolve()
-	if len(errs) < 3 {
-		t.Fatalf("expected at least 3 errors, got %d: %v", len(errs), errs)
+	if len(errs) != 1 {
+		t.Fatalf("expected 1 error, got %d: %v", len(errs), errs)
+	}
+	if !strings.Contains(errs[0].Error(), "valid values are abort, continue, warn") {
+		t.Errorf("error = %q, want it to list valid values", errs[0])
 	}
 }
 
-func TestResolve_EmptyConfig(t *testing.T) {
-	cfg := &Config{}
+func TestResolve_Retries(t *testing.T) {
+	cfg := &Config{
+		Hooks: map[string][]HookCommand{
+			"pre-push": {
+				{Run: "lint"},
+				{Run: "integration", Retries: 2, RetryDelay: "2s", RetryBackoff: 1.5, RetryOnTimeout: true},
+			},
+		},
+	}
 
 	resolved, errs := cfg.Resolve()
 	if len(errs) > 0 {
 		t.Fatalf("Resolve() errors = %v", errs)
 	}
-	if resolved.Timeout != DefaultTimeout {
-		t.Errorf("Timeout = %v, want %v", resolved.Timeout, DefaultTimeout)
+	cmds := resolved.Hooks["pre-push"]
+	if cmds[0].Retries != 0 || cmds[0].RetryBackoff != 1 {
+		t.Errorf("defaults = %d retries, backoff %g; want 0, 1", cmds[0].Retries, cmds[0].RetryBackoff)
 	}
-	if resolved.LogLevel != LogWarn {
-		t.Errorf("LogLevel = %v, want LogWarn", resolved.LogLevel)
+	if cmds[1].Retries != 2 || cmds[1].RetryDelay != 2*time.Second || cmds[1].RetryBackoff != 1.5 || !cmds[1].RetryOnTimeout {
+		t.Errorf("retry policy = %+v", cmds[1])
 	}
 }
 
-func TestValidateHookName(t *testing.T) {
-	if err := ValidateHookName("pre-commit"); err != nil {
-		t.Errorf("ValidateHookName('pre-commit') should be valid: %v", err)
+func TestResolve_InvalidRetries(t *testing.T) {
+	cfg := &Config{
+		Hooks: map[string][]HookCommand{
+			"pre-push": {
+				{Run: "a", Retries: -1},
+				{Run: "b", Retries: 1, RetryDelay: "soon"},
+				{Run: "c", Retries: 1, RetryDelay: "-1s"},
+				{Run: "d", Retries: 1, RetryBackoff: 0.5},
+			},
+		},
 	}
-	if err := ValidateHookName("not-a-hook"); err == nil {
-		t.Error("ValidateHookName('not-a-hook') should be invalid")
+
+	_, errs := cfg.Resolve()
+	if len(errs) != 4 {
+		t.Fatalf("expected 4 errors, got %d: %v", len(errs), errs)
+	}
+	for i, want := range []string{"invalid retries -1", "invalid retry_delay \"soon\"", "must not be negative", "invalid retry_backoff 0.5"} {
+		if !strings.Contains(errs[i].Error(), want) {
+			t.Errorf("error %d = %q, want it to contain %q", i, errs[i], want)
+		}
 	}
 }
 
-func TestSuggestHookName(t *testing.T) {
-	tests := []struct {
-		input    string
-		expected string
-	}{
-		{"pre-comit", "pre-commit"},
-		{"pre-commti", "pre-commit"},
-		{"comit-msg", "commit-msg"},
-		{"xyzxyzxyz", ""},
+func TestResolve_KillGrace(t *testing.T) {
+	cfg := &Config{
+		KillGrace: "10s",
+		Hooks: map[string][]HookCommand{
+			"pre-push": {
+				{Run: "a"},
+				{Run: "b", KillGrace: "0s"},
+				{Run: "c", KillGrace: "-1s"},
+			},
+		},
 	}
 
-	for _, tt := range tests {
-		t.Run(tt.input, func(t *testing.T) {
-			got := SuggestHookName(tt.input)
-			if got != tt.expected {
-				t.Errorf("SuggestHookName(%q) = %q, want %q", tt.input, got, tt.expected)
-			}
-		})
+	_, errs := cfg.Resolve()
+	if len(errs) != 1 || !strings.Contains(errs[0].Error(), "invalid kill_grace \"-1s\"") {
+		t.Fatalf("expected one kill_grace error, got %v", errs)
+	}
+
+	cfg.Hooks["pre-push"] = cfg.Hooks["pre-push"][:2]
+	resolved, errs := cfg.Resolve()
+	if len(errs) > 0 {
+		t.Fatalf("Resolve() errors = %v", errs)
+	}
+	cmds := resolved.Hooks["pre-push"]
+	if cmds[0].KillGrace != 10*time.Second || cmds[1].KillGrace != 0 {
+		t.Errorf("KillGrace = %s, %s; want 10s, 0s", cmds[0].KillGrace, cmds[1].KillGrace)
+	}
+
+	resolved, _ = (&Config{}).Resolve()
+	if resolved.KillGrace != DefaultKillGrace {
+		t.Errorf("default KillGrace = %s, want %s", resolved.KillGrace, DefaultKillGrace)
 	}
 }
 
-func writeTempFile(t *testing.T, content string) string {
-	t.Helper()
-	tmpfile, err := os.CreateTemp("", "test-*.yml")
+func TestResolve_EnvAndDir(t *testing.T) {
+	cfg := &Config{
+		Hooks: map[string][]HookCommand{
+			"pre-commit": {
+				{Run: "go test ./...", Dir: "services/api/", EnvFile: "./.env.test", Env: map[string]string{"GOFLAGS": "-mod=mod", "CGO_ENABLED": "0"}},
+				{Run: "make", Dir: "."},
+			},
+		},
+	}
+
+	resolved, errs := cfg.Resolve()
+	if len(errs) > 0 {
+		t.Fatalf("Resolve() errors = %v", errs)
+	}
+	cmd := resolved.Hooks["pre-commit"][0]
+	if cmd.Dir != "services/api" || cmd.EnvFile != ".env.test" {
+		t.Errorf("Dir, EnvFile = %q, %q; want services/api, .env.test", cmd.Dir, cmd.EnvFile)
+	}
+	if len(cmd.Env) != 2 || cmd.Env[0].Name != "CGO_ENABLED" || cmd.Env[1].Name != "GOFLAGS" {
+		t.Errorf("Env = %+v, want CGO_ENABLED and GOFLAGS in order", cmd.Env)
+	}
+	if dir := resolved.Hooks["pre-commit"][1].Dir; dir != "" {
+		t.Errorf("Dir = %q, want the repo root", dir)
+	}
+}
+
+func TestResolve_InvalidEnvAndDir(t *testing.T) {
+	cfg := &Config{
+		Hooks: map[string][]HookCommand{
+			"pre-commit": {
+				{Run: "a", Env: map[string]string{"1BAD": "x"}},
+				{Run: "b", Dir: "../elsewhere"},
+				{Run: "c", Dir: "/tmp"},
+				{Run: "d", EnvFile: "web/../../.env"},
+			},
+		},
+	}
+
+	_, errs := cfg.Resolve()
+	if len(errs) != 4 {
+		t.Fatalf("expected 4 errors, got %d: %v", len(errs), errs)
+	}
+	for i, want := range []string{"invalid env name \"1BAD\"", "invalid dir \"../elsewhere\"", "invalid dir \"/tmp\"", "invalid env_file"} {
+		if !strings.Contains(errs[i].Error(), want) {
+			t.Errorf("error %d = %q, want it to contain %q", i, errs[i], want)
+		}
+	}
+}
+
+func TestParseEnvFile(t *testing.T) {
+	input := `# comment
+export API_URL=http://localhost:8080
+PLAIN = value # trailing comment
+QUOTED="line1\nline2 \"q\""
+LITERAL='${HOME} stays'
+
+EMPTY=
+`
+	vars, err := parseEnvFile(strings.NewReader(input))
 	if err != nil {
-		t.Fatal(err)
+		t.Fatalf("parseEnvFile() error = %v", err)
 	}
-	if _, err := tmpfile.Write([]byte(content)); err != nil {
-		t.Fatal(err)
+
+	want := []EnvVar{
+		{Name: "API_URL", Value: "http://localhost:8080"},
+		{Name: "PLAIN", Value: "value"},
+		{Name: "QUOTED", Value: "line1\nline2 \"q\""},
+		{Name: "LITERAL", Value: "${HOME} stays", Literal: true},
+		{Name: "EMPTY", Value: ""},
 	}
-	if err := tmpfile.Close(); err != nil {
-		t.Fatal(err)
+	if len(vars) != len(want) {
+		t.Fatalf("got %d vars, want %d: %+v", len(vars), len(want), vars)
+	}
+	for i := range want {
+		if vars[i] != want[i] {
+			t.Errorf("var %d = %+v, want %+v", i, vars[i], want[i])
+		}
+	}
+
+	if _, err := parseEnvFile(strings.NewReader("OK=1\nnot a var\n")); err == nil || !strings.Contains(err.Error(), "line 2") {
+		t.Errorf("parseEnvFile() error = %v, want a line 2 error", err)
+	}
+}
+
+func TestExpandEnv(t *testing.T) {
+	lookup := func(name string) (string, bool) {
+		if name == "HOME" {
+			return "/home/dev", true
+		}
+		return "", false
+	}
+	got := ExpandEnv("${HOME}/bin:${MISSING}:$HOME", lookup)
+	if want := "/home/dev/bin::$HOME"; got != want {
+		t.Errorf("ExpandEnv() = %q, want %q", got, want)
+	}
+}
+
+func TestResolve_Shell(t *testing.T) {
+	cfg := &Config{
+		Hooks: map[string][]HookCommand{
+			"pre-commit": {
+				{Run: "a"},
+				{Run: "b", Shell: StringList{"bash"}},
+				{Run: "c", Shell: StringList{"python3", "-c"}},
+			},
+		},
+	}
+
+	resolved, errs := cfg.Resolve()
+	if len(errs) > 0 {
+		t.Fatalf("Resolve() errors = %v", errs)
+	}
+	cmds := resolved.Hooks["pre-commit"]
+	if cmds[0].Shell.Name != "sh" || !cmds[0].Shell.POSIX() {
+		t.Errorf("default shell = %+v, want sh", cmds[0].Shell)
+	}
+	if cmds[1].Shell.Name != "bash" || strings.Join(cmds[1].Shell.Argv, " ") != "bash -c" {
+		t.Errorf("bash shell = %+v", cmds[1].Shell)
+	}
+	if cmds[2].Shell.Name != "" || strings.Join(cmds[2].Shell.Argv, " ") != "python3 -c" || cmds[2].Shell.POSIX() {
+		t.Errorf("custom shell = %+v", cmds[2].Shell)
+	}
+
+	cfg.Hooks["pre-commit"] = []HookCommand{{Run: "d", Shell: StringList{"fish"}}}
+	if _, errs := cfg.Resolve(); len(errs) != 1 || !strings.Contains(errs[0].Error(), `unknown shell "fish"`) {
+		t.Errorf("expected an unknown shell error, got %v", errs)
+	}
+
+	// Placeholders need a shell whose quoting is known
+	cfg.Hooks["pre-commit"] = []HookCommand{
+		{Run: "fmt {staged_files}", Shell: StringList{"/usr/bin/bash", "-eo", "pipefail", "-c"}},
+		{Run: "fmt {staged_files}", Shell: StringList{"pwsh"}},
+		{Run: "print('${args}')", Shell: StringList{"python3", "-c"}},
+		{Run: "print({args})", Shell: StringList{"python3", "-c"}},
+	}
+	if _, errs := cfg.Resolve(); len(errs) != 1 || !strings.Contains(errs[0].Error(), `command #4: placeholders such as {staged_files} cannot be quoted for shell "python3 -c"`) {
+		t.Errorf("expected one placeholder quoting error, got %v", errs)
+	}
+}
+
+func TestLoad_ScriptCommands(t *testing.T) {
+	dir := t.TempDir()
+	if err := os.MkdirAll(filepath.Join(dir, ScriptsDir, "checks"), 0o755); err != nil {
+		t.Fatal(err)
+	}
+	scripts := map[string]string{
+		"lint.py":         "#!/usr/bin/env python3\nprint('ok')\n",
+		"checks/size.sh":  "#!/bin/bash -e\necho ok\n",
+		"plain.sh":        "echo ok\n",
+		"checks/split.sh": "#!/usr/bin/env -S node --no-warnings\n",
+	}
+	for name, content := range scripts {
+		if err := os.WriteFile(filepath.Join(dir, ScriptsDir, name), []byte(content), 0o644); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	yamlContent := `
+hooks:
+  pre-commit:
+    - script: lint.py
+    - script: checks/size.sh
+    - script: plain.sh
+    - script: checks/split.sh
+`
+	path := filepath.Join(dir, ".githooksrc.yml")
+	if err := os.WriteFile(path, []byte(yamlContent), 0o644); err != nil {
+		t.Fatal(err)
+	}
+
+	cfg, err := Load(path)
+	if err != nil {
+		t.Fatalf("Load() error = %v", err)
+	}
+	resolved, errs := cfg.Resolve()
+	if len(errs) > 0 {
+		t.Fatalf("Resolve() errors = %v", errs)
+	}
+
+	want := []struct{ script, interpreter string }{
+		{".githooks/lint.py", "python3"},
+		{".githooks/checks/size.sh", "/bin/bash -e"},
+		{".githooks/plain.sh", ""},
+		{".githooks/checks/split.sh", "node --no-warnings"},
+	}
+	for i, cmd := range resolved.Hooks["pre-commit"] {
+		if path := filepath.Join(dir, filepath.FromSlash(want[i].script)); cmd.Script != path || cmd.Run != want[i].script {
+			t.Errorf("command #%d Script, Run = %q, %q; want %q, %q", i+1, cmd.Script, cmd.Run, path, want[i].script)
+		}
+		if got := strings.Join(cmd.Interpreter, " "); got != want[i].interpreter {
+			t.Errorf("command #%d Interpreter = %q, want %q", i+1, got, want[i].interpreter)
+		}
+	}
+}
+
+func TestResolve_InvalidScript(t *testing.T) {
+	cfg := &Config{
+		dir: t.TempDir(),
+		Hooks: map[string][]HookCommand{
+			"pre-commit": {
+				{Script: "missing.sh"},
+				{Script: "../outside.sh"},
+				{Script: "lint.sh", Run: "make lint"},
+				{Script: "lint.sh", Shell: StringList{"bash"}},
+			},
+		},
+	}
+
+	_, errs := cfg.Resolve()
+	if len(errs) != 4 {
+		t.Fatalf("expected 4 errors, got %d: %v", len(errs), errs)
+	}
+	for i, want := range []string{".githooks/missing.sh does not exist", "must be a relative path inside .githooks/", "cannot both be set", "'shell' cannot be used with 'script'"} {
+		if !strings.Contains(errs[i].Error(), want) {
+			t.Errorf("error %d = %q, want it to contain %q", i, errs[i], want)
+		}
+	}
+}
+
+func TestResolve_Needs(t *testing.T) {
+	cfg := &Config{
+		Hooks: map[string][]HookCommand{
+			"pre-push": {
+				{ID: "test", Run: "go test ./...", Needs: StringList{"build"}},
+				{ID: "build", Run: "go build ./..."},
+				{ID: "lint", Run: "golangci-lint run"},
+			},
+		},
+	}
+
+	resolved, errs := cfg.Resolve()
+	if len(errs) > 0 {
+		t.Fatalf("Resolve() errors = %v", errs)
+	}
+	if needs := resolved.Hooks["pre-push"][0].Needs; len(needs) != 1 || needs[0] != "build" {
+		t.Errorf("Needs = %v, want [build]", needs)
+	}
+}
+
+func TestResolve_InvalidNeeds(t *testing.T) {
+	cfg := &Config{
+		Hooks: map[string][]HookCommand{
+			"pre-push": {
+				{ID: "a", Run: "a", Needs: StringList{"b"}},
+				{ID: "b", Run: "b", Needs: StringList{"c"}},
+				{ID: "c", Run: "c", Needs: StringList{"a"}},
+				{ID: "d", Run: "d", Needs: StringList{"d", "missing"}},
+			},
+		},
+	}
+
+	_, errs := cfg.Resolve()
+	if len(errs) != 3 {
+		t.Fatalf("expected 3 errors, got %d: %v", len(errs), errs)
+	}
+	for i, want := range []string{
+		`command #4: needs unknown id "missing"`,
+		"dependency cycle: a -> b -> c -> a",
+		"dependency cycle: d -> d",
+	} {
+		if !strings.Contains(errs[i].Error(), want) {
+			t.Errorf("error %d = %q, want it to contain %q", i, errs[i], want)
+		}
+	}
+}
+
+func TestResolve_Chains(t *testing.T) {
+	cfg := &Config{
+		Hooks: map[string][]HookCommand{
+			"pre-commit": {
+				{Run: "echo first"},
+				{Chain: "check", Timeout: "2m", Env: map[string]string{"MODE": "strict"}},
+			},
+		},
+		Chains: map[string][]HookCommand{
+			"check": {
+				{Chain: "lint"},
+				{Run: "go test ./...", Env: map[string]string{"MODE": "fast", "CGO_ENABLED": "0"}},
+			},
+			"lint": {
+				{ID: "vet", Run: "go vet ./...", Timeout: "10s"},
+			},
+		},
+	}
+
+	resolved, errs := cfg.Resolve()
+	if len(errs) > 0 {
+		t.Fatalf("Resolve() errors = %v", errs)
+	}
+	cmds := resolved.Hooks["pre-commit"]
+	if len(cmds) != 3 {
+		t.Fatalf("expected 3 commands, got %d", len(cmds))
+	}
+	for i, want := range []string{"echo first", "go vet ./...", "go test ./..."} {
+		if cmds[i].Run != want {
+			t.Errorf("command %d Run = %q, want %q", i, cmds[i].Run, want)
+		}
+	}
+	if cmds[1].ID != "vet" {
+		t.Errorf("ID = %q, want vet", cmds[1].ID)
+	}
+	for _, i := range []int{1, 2} {
+		if cmds[i].Timeout != 2*time.Minute {
+			t.Errorf("command %d Timeout = %v, want 2m", i, cmds[i].Timeout)
+		}
+	}
+	want := []EnvVar{{Name: "CGO_ENABLED", Value: "0"}, {Name: "MODE", Value: "strict"}}
+	if !reflect.DeepEqual(cmds[2].Env, want) {
+		t.Errorf("Env = %v, want %v", cmds[2].Env, want)
+	}
+}
+
+func TestResolve_InvalidChains(t *testing.T) {
+	cfg := &Config{
+		Hooks: map[string][]HookCommand{
+			"pre-commit": {
+				{Chain: "a"},
+				{Chain: "missing"},
+				{Chain: "ok", Run: "echo"},
+				{Chain: "ok"},
+			},
+		},
+		Chains: map[string][]HookCommand{
+			"a":  {{Chain: "b"}},
+			"b":  {{Chain: "a"}},
+			"ok": {{Run: "echo ok", Timeout: "banana"}},
+		},
+	}
+
+	_, errs := cfg.Resolve()
+	if len(errs) != 4 {
+		t.Fatalf("expected 4 errors, got %d: %v", len(errs), errs)
+	}
+	for i, want := range []string{
+		`command #1 (chain "a" command #1 > chain "b" command #1): chain cycle: a -> b -> a`,
+		`command #2: unknown chain "missing"`,
+		`command #3: chain reference "ok" can only set 'timeout' and 'env'`,
+		`command #4 (chain "ok" command #1): invalid timeout`,
+	} {
+		if !strings.Contains(errs[i].Error(), want) {
+			t.Errorf("error %d = %q, want it to contain %q", i, errs[i], want)
+		}
+	}
+}
+
+func TestExpandChain(t *testing.T) {
+	cfg := &Config{
+		Chains: map[string][]HookCommand{
+			"all":  {{Run: "echo 1"}, {Chain: "lint"}},
+			"lint": {{Run: "echo 2"}},
+		},
+	}
+
+	cmds, err := cfg.ExpandChain("all")
+	if err != nil {
+		t.Fatalf("ExpandChain() error = %v", err)
+	}
+	if len(cmds) != 2 || cmds[1].Run != "echo 2" {
+		t.Errorf("ExpandChain() = %v", cmds)
+	}
+	if _, err := cfg.ExpandChain("nope"); err == nil {
+		t.Error("expected error for unknown chain")
+	}
+}
+
+func TestResolve_MultipleErrors(t *testing.T) {
+	cfg := &Config{
+		Timeout:  "banana",
+		LogLevel: "verbose",
+		Hooks: map[string][]HookCommand{
+			"pre-commit": {
+				{Description: "no run"},
+			},
+		},
+	}
+
+	_, errs := cfg.Resolve()
+	if len(errs) < 3 {
+		t.Fatalf("expected at least 3 errors, got %d: %v", len(errs), errs)
+	}
+}
+
+func TestResolve_EmptyConfig(t *testing.T) {
+	cfg := &Config{}
+
+	resolved, errs := cfg.Resolve()
+	if len(errs) > 0 {
+		t.Fatalf("Resolve() errors = %v", errs)
+	}
+	if resolved.Timeout != DefaultTimeout {
+		t.Errorf("Timeout = %v, want %v", resolved.Timeout, DefaultTimeout)
+	}
+	if resolved.LogLevel != LogWarn {
+		t.Errorf("LogLevel = %v, want LogWarn", resolved.LogLevel)
+	}
+}
+
+func TestValidateHookName(t *testing.T) {
+	if err := ValidateHookName("pre-commit"); err != nil {
+		t.Errorf("ValidateHookName('pre-commit') should be valid: %v", err)
+	}
+	if err := ValidateHookName("not-a-hook"); err == nil {
+		t.Error("ValidateHookName('not-a-hook') should be invalid")
+	}
+}
+
+func TestReceivesStdin(t *testing.T) {
+	if !ReceivesStdin("pre-push") {
+		t.Error("ReceivesStdin('pre-push') = false, want true")
+	}
+	if ReceivesStdin("pre-commit") {
+		t.Error("ReceivesStdin('pre-commit') = true, want false")
+	}
+}
+
+func TestSuggestHookName(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected string
+	}{
+		{"pre-comit", "pre-commit"},
+		{"pre-commti", "pre-commit"},
+		{"comit-msg", "commit-msg"},
+		{"xyzxyzxyz", ""},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.input, func(t *testing.T) {
+			got := SuggestHookName(tt.input)
+			if got != tt.expected {
+				t.Errorf("SuggestHookName(%q) = %q, want %q", tt.input, got, tt.expected)
+			}
+		})
+	}
+}
+
+func writeTempFile(t *testing.T, content string) string {
+	t.Helper()
+	tmpfile, err := os.CreateTemp("", "test-*.yml")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := tmpfile.Write([]byte(content)); err != nil {
+		t.Fatal(err)
+	}
+	if err := tmpfile.Close(); err != nil {
+		t.Fatal(err)
+	}
+	return tmpfile.Name()
+}
+
+func TestLoadLayered(t *testing.T) {
+	dir := t.TempDir()
+	write := func(path, content string) {
+		t.Helper()
+		if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(path, []byte(content), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	system := filepath.Join(dir, "etc", "config.yml")
+	write(system, `
+log_level: debug
+timeout: 10s
+`)
+	orig := SystemConfigPath
+	SystemConfigPath = system
+	defer func() { SystemConfigPath = orig }()
+
+	t.Setenv("XDG_CONFIG_HOME", filepath.Join(dir, "xdg"))
+	user := filepath.Join(dir, "xdg", "githookd", "config.yml")
+	write(user, `
+hooks:
+  pre-commit:
+    - run: echo user
+`)
+
+	repo := filepath.Join(dir, "repo", ".githooksrc.yml")
+	write(repo, `
+timeout: 1m
+hooks:
+  pre-commit:
+    - id: lint
+      run: npm run lint
+      env:
+        A: "1"
+    - id: slow
+      run: make slow
+  pre-push:
+    - run: make test
+`)
+	local := filepath.Join(dir, "repo", ".githooksrc.local.yml")
+	write(local, `
+timeout: 2m
+merge:
+  pre-push: replace
+hooks:
+  pre-commit:
+    - id: lint
+      timeout: 5m
+      env:
+        B: "2"
+    - id: slow
+      enabled: false
+    - run: echo mine
+  pre-push:
+    - run: echo push
+`)
+
+	cfg, err := LoadLayered(repo, LoadOptions{})
+	if err != nil {
+		t.Fatalf("LoadLayered() error = %v", err)
+	}
+	if cfg.Timeout != "2m" || cfg.LogLevel != "debug" {
+		t.Errorf("Timeout = %q, LogLevel = %q, want 2m, debug", cfg.Timeout, cfg.LogLevel)
+	}
+	if cfg.Origin("timeout") != local || cfg.Origin("log_level") != system || cfg.Origin("kill_grace") != OriginDefault {
+		t.Errorf("origins = %q, %q, %q", cfg.Origin("timeout"), cfg.Origin("log_level"), cfg.Origin("kill_grace"))
+	}
+
+	pre := cfg.Hooks["pre-commit"]
+	var labels []string
+	for _, c := range pre {
+		labels = append(labels, c.Label())
+	}
+	if want := []string{"echo user", "npm run lint", "make slow", "echo mine"}; !reflect.DeepEqual(labels, want) {
+		t.Fatalf("pre-commit = %v, want %v", labels, want)
+	}
+	lint := pre[1]
+	if lint.Timeout != "5m" || !reflect.DeepEqual(lint.Env, map[string]string{"A": "1", "B": "2"}) {
+		t.Errorf("lint = %+v", lint)
+	}
+	if lint.Origin("run") != repo || lint.Origin("timeout") != local || lint.Origin("env.A") != repo || lint.Origin("env.B") != local {
+		t.Errorf("lint origins = %v", lint.origins)
+	}
+	if pre[2].IsEnabled() {
+		t.Error("slow should be disabled by the local layer")
+	}
+	if push := cfg.Hooks["pre-push"]; len(push) != 1 || push[0].Run != "echo push" {
+		t.Errorf("pre-push = %v, want only echo push", push)
+	}
+	if cfg.Merge != nil {
+		t.Errorf("Merge = %v, want nil after merging", cfg.Merge)
+	}
+	if _, errs := cfg.Resolve(); len(errs) > 0 {
+		t.Errorf("Resolve() errors = %v", errs)
+	}
+}
+
+func TestLoadLayered_FalseAndZeroOverride(t *testing.T) {
+	dir := t.TempDir()
+	orig := SystemConfigPath
+	SystemConfigPath = ""
+	defer func() { SystemConfigPath = orig }()
+	t.Setenv("XDG_CONFIG_HOME", dir)
+
+	repo := filepath.Join(dir, ".githooksrc.yml")
+	os.WriteFile(repo, []byte(`
+stash_unstaged: true
+hooks:
+  pre-commit:
+    - id: fmt
+      run: gofmt -w .
+      parallel: true
+      stage_fixed: true
+      retries: 2
+`), 0644)
+	local := filepath.Join(dir, ".githooksrc.local.yml")
+	os.WriteFile(local, []byte(`
+stash_unstaged: false
+hooks:
+  pre-commit:
+    - id: fmt
+      parallel: false
+      stage_fixed: false
+      retries: 0
+`), 0644)
+
+	cfg, err := LoadLayered(repo, LoadOptions{})
+	if err != nil {
+		t.Fatalf("LoadLayered() error = %v", err)
+	}
+	if cfg.StashUnstaged || cfg.Origin("stash_unstaged") != local {
+		t.Errorf("StashUnstaged = %v from %s, want false from the local layer", cfg.StashUnstaged, cfg.Origin("stash_unstaged"))
+	}
+	fmtCmd := cfg.Hooks["pre-commit"][0]
+	if fmtCmd.Parallel || fmtCmd.StageFixed || fmtCmd.Retries != 0 {
+		t.Errorf("fmt = %+v, want parallel, stage_fixed and retries turned off", fmtCmd)
+	}
+	if fmtCmd.ID != "fmt" || fmtCmd.Origin("id") != repo || fmtCmd.Origin("retries") != local {
+		t.Errorf("fmt id %q from %s, retries from %s", fmtCmd.ID, fmtCmd.Origin("id"), fmtCmd.Origin("retries"))
+	}
+}
+
+func TestLoadLayered_Errors(t *testing.T) {
+	dir := t.TempDir()
+	orig := SystemConfigPath
+	SystemConfigPath = ""
+	defer func() { SystemConfigPath = orig }()
+	t.Setenv("XDG_CONFIG_HOME", dir)
+
+	repo := filepath.Join(dir, ".githooksrc.yml")
+	if _, err := LoadLayered(repo, LoadOptions{}); err == nil {
+		t.Error("expected error for missing repository config")
+	}
+
+	os.WriteFile(repo, []byte("hooks: {}\n"), 0644)
+	os.WriteFile(LocalPath(repo), []byte("merge:\n  pre-commit: prepend\n"), 0644)
+	_, err := LoadLayered(repo, LoadOptions{})
+	if err == nil || !strings.Contains(err.Error(), `invalid strategy "prepend"`) {
+		t.Errorf("error = %v, want invalid strategy", err)
+	}
+}
+
+func TestLoadLayered_Extends(t *testing.T) {
+	dir := t.TempDir()
+	orig := SystemConfigPath
+	SystemConfigPath = ""
+	defer func() { SystemConfigPath = orig }()
+	t.Setenv("XDG_CONFIG_HOME", filepath.Join(dir, "xdg"))
+
+	git := func(dir string, args ...string) {
+		t.Helper()
+		cmd := exec.Command("git", args...)
+		cmd.Dir = dir
+		cmd.Env = append(os.Environ(), "GIT_AUTHOR_NAME=t", "GIT_AUTHOR_EMAIL=t@t", "GIT_COMMITTER_NAME=t", "GIT_COMMITTER_EMAIL=t@t")
+		if out, err := cmd.CombinedOutput(); err != nil {
+			t.Fatalf("git %v: %v\n%s", args, err, out)
+		}
+	}
+
+	// A shared repository with a base config that extends a sibling file
+	shared := filepath.Join(dir, "shared")
+	os.MkdirAll(filepath.Join(shared, "hooks"), 0755)
+	git(shared, "init", "-q")
+	os.WriteFile(filepath.Join(shared, "hooks", "base.yml"), []byte(`
+extends: common.yml
+hooks:
+  pre-commit:
+    - id: lint
+      run: make lint
+`), 0644)
+	os.WriteFile(filepath.Join(shared, "hooks", "common.yml"), []byte("timeout: 1m\n"), 0644)
+	git(shared, "add", "-A")
+	git(shared, "commit", "-qm", "v1")
+	git(shared, "tag", "v1")
+
+	os.WriteFile(filepath.Join(dir, "team.yml"), []byte(`
+hooks:
+  pre-commit:
+    - id: fmt
+      run: make fmt
+`), 0644)
+	repo := filepath.Join(dir, ".githooksrc.yml")
+	os.WriteFile(repo, []byte(`
+extends:
+  - repo: shared
+    ref: main
+    path: hooks/base.yml
+  - team.yml
+hooks:
+  pre-commit:
+    - id: lint
+      timeout: 5m
+`), 0644)
+	git(shared, "branch", "-M", "main")
+
+	opts := LoadOptions{CachePath: filepath.Join(dir, "cache", "extends.json")}
+	cfg, err := LoadLayered(repo, opts)
+	if err != nil {
+		t.Fatalf("LoadLayered() error = %v", err)
+	}
+	if _, errs := cfg.Resolve(); len(errs) > 0 {
+		t.Fatalf("Resolve() errors = %v", errs)
+	}
+	pre := cfg.Hooks["pre-commit"]
+	if len(pre) != 2 || pre[0].Run != "make lint" || pre[0].Timeout != "5m" || pre[1].Run != "make fmt" {
+		t.Fatalf("pre-commit = %+v", pre)
+	}
+	if cfg.Timeout != "1m" {
+		t.Errorf("Timeout = %q, want 1m from the extended config", cfg.Timeout)
+	}
+	if want := shared + "@main:hooks/base.yml"; pre[0].Origin("run") != want {
+		t.Errorf("origin = %q, want %q", pre[0].Origin("run"), want)
+	}
+
+	// The cached commit is used until the extends are updated
+	os.WriteFile(filepath.Join(shared, "hooks", "common.yml"), []byte("timeout: 2m\n"), 0644)
+	git(shared, "commit", "-qam", "v2")
+	if cfg, _ = LoadLayered(repo, opts); cfg.Timeout != "1m" {
+		t.Errorf("Timeout = %q, want the cached 1m", cfg.Timeout)
+	}
+	updates, err := UpdateExtends(repo, opts)
+	if err != nil {
+		t.Fatalf("UpdateExtends() error = %v", err)
+	}
+	if len(updates) != 2 || updates[0].From == "" || updates[0].From == updates[0].To {
+		t.Errorf("updates = %+v, want 2 changed commits", updates)
+	}
+	if cfg, _ = LoadLayered(repo, opts); cfg.Timeout != "2m" {
+		t.Errorf("Timeout = %q, want 2m after update", cfg.Timeout)
+	}
+}
+
+func TestLoadLayered_CircularExtends(t *testing.T) {
+	dir := t.TempDir()
+	orig := SystemConfigPath
+	SystemConfigPath = ""
+	defer func() { SystemConfigPath = orig }()
+	t.Setenv("XDG_CONFIG_HOME", filepath.Join(dir, "xdg"))
+
+	repo := filepath.Join(dir, ".githooksrc.yml")
+	os.WriteFile(repo, []byte("extends: a.yml\n"), 0644)
+	os.WriteFile(filepath.Join(dir, "a.yml"), []byte("extends: b.yml\n"), 0644)
+	os.WriteFile(filepath.Join(dir, "b.yml"), []byte("extends: a.yml\n"), 0644)
+
+	cfg, err := LoadLayered(repo, LoadOptions{})
+	if err != nil {
+		t.Fatalf("LoadLayered() error = %v", err)
+	}
+	_, errs := cfg.Resolve()
+	if len(errs) != 1 {
+		t.Fatalf("expected 1 error, got %v", errs)
+	}
+	a, b := filepath.Join(dir, "a.yml"), filepath.Join(dir, "b.yml")
+	if want := "circular extends: " + a + " -> " + b + " -> " + a; errs[0].Error() != want {
+		t.Errorf("error = %q, want %q", errs[0], want)
+	}
+}
+
+func TestLoadLayered_DiamondExtends(t *testing.T) {
+	dir := t.TempDir()
+	orig := SystemConfigPath
+	SystemConfigPath = ""
+	defer func() { SystemConfigPath = orig }()
+	t.Setenv("XDG_CONFIG_HOME", filepath.Join(dir, "xdg"))
+
+	// The repository config extends b and c, which both extend d
+	repo := filepath.Join(dir, ".githooksrc.yml")
+	os.WriteFile(repo, []byte("extends: [b.yml, c.yml]\nhooks:\n  pre-commit:\n    - run: make a\n"), 0644)
+	os.WriteFile(filepath.Join(dir, "b.yml"), []byte("extends: d.yml\nhooks:\n  pre-commit:\n    - run: make b\n"), 0644)
+	os.WriteFile(filepath.Join(dir, "c.yml"), []byte("extends: ./d.yml\nhooks:\n  pre-commit:\n    - run: make c\n"), 0644)
+	os.WriteFile(filepath.Join(dir, "d.yml"), []byte("hooks:\n  pre-commit:\n    - run: make d\n"), 0644)
+
+	cfg, err := LoadLayered(repo, LoadOptions{})
+	if err != nil {
+		t.Fatalf("LoadLayered() error = %v", err)
+	}
+	var runs []string
+	for _, c := range cfg.Hooks["pre-commit"] {
+		runs = append(runs, c.Run)
+	}
+	if got := strings.Join(runs, ", "); got != "make d, make b, make c, make a" {
+		t.Errorf("commands = %s, want make d once, then make b, make c, make a", got)
+	}
+}
+
+func TestLoad_UnknownKeys(t *testing.T) {
+	path := filepath.Join(t.TempDir(), ".githooksrc.yml")
+	os.WriteFile(path, []byte(`timout: 1m
+hooks:
+  pre-commit:
+    - run: npm run lint
+      desciption: "Run ESLint"
+      when:
+        brnch: main
+`), 0644)
+
+	cfg, err := Load(path)
+	if err != nil {
+		t.Fatalf("Load() error = %v", err)
+	}
+	_, errs := cfg.Resolve()
+	if len(errs) != 3 {
+		t.Fatalf("expected 3 errors, got %d: %v", len(errs), errs)
+	}
+	for i, want := range []struct {
+		msg, hint string
+		line, col int
+	}{
+		{`unknown key "timout" in config`, `did you mean "timeout"?`, 1, 1},
+		{`unknown key "desciption" in command`, `did you mean "description"?`, 5, 7},
+		{`unknown key "brnch" in when`, `did you mean "branch"?`, 7, 9},
+	} {
+		var cerr *Error
+		if !errors.As(errs[i], &cerr) {
+			t.Fatalf("error %d = %T, want *Error", i, errs[i])
+		}
+		if cerr.Err.Error() != want.msg || cerr.Hint != want.hint {
+			t.Errorf("error %d = %q (%s), want %q (%s)", i, cerr.Err, cerr.Hint, want.msg, want.hint)
+		}
+		if cerr.Pos.File != path || cerr.Pos.Line != want.line || cerr.Pos.Column != want.col {
+			t.Errorf("error %d at %s, want line %d col %d", i, cerr.Pos, want.line, want.col)
+		}
+	}
+}
+
+func TestResolve_ErrorPositions(t *testing.T) {
+	path := filepath.Join(t.TempDir(), ".githooksrc.yml")
+	os.WriteFile(path, []byte(`log_level: loud
+hooks:
+  pre-commit:
+    - run: make lint
+      timeout: soon
+    - description: no run
+`), 0644)
+
+	cfg, err := Load(path)
+	if err != nil {
+		t.Fatalf("Load() error = %v", err)
+	}
+	_, errs := cfg.Resolve()
+	if len(errs) != 3 {
+		t.Fatalf("expected 3 errors, got %d: %v", len(errs), errs)
+	}
+	for i, want := range []string{
+		path + `:1:12: invalid global log_level "loud"`,
+		path + `:5:16: hook "pre-commit" command #1: invalid timeout "soon"`,
+		path + `:6:7: hook "pre-commit" command #2: 'run' field is required`,
+	} {
+		if !strings.HasPrefix(errs[i].Error(), want) {
+			t.Errorf("error %d = %q, want prefix %q", i, errs[i], want)
+		}
+	}
+	var cerr *Error
+	if errors.As(errs[1], &cerr) && cerr.Source != "      timeout: soon" {
+		t.Errorf("Source = %q", cerr.Source)
+	}
+}
+
+func TestDocument_Edits(t *testing.T) {
+	const original = `# Project hooks
+timeout: "30s"   # generous
+
+hooks:
+  pre-commit:
+    # lint first
+    - run: "npm run lint"   # fast
+      description: 'Run ESLint'
+
+    # then tests
+    - id: test
+      run: npm test
+  # trailing note
+
+  commit-msg:
+    - run: ./check.sh
+# end
+`
+	tests := []struct {
+		name string
+		edit func(d *Document) error
+		want string
+	}{
+		{
+			name: "disable",
+			edit: func(d *Document) error { return d.SetEnabled("pre-commit", 1, false) },
+			want: strings.Replace(original, "      run: npm test\n", "      run: npm test\n      enabled: false\n", 1),
+		},
+		{
+			name: "enable already enabled",
+			edit: func(d *Document) error { return d.SetEnabled("pre-commit", 0, true) },
+			want: original,
+		},
+		{
+			name: "add to existing hook",
+			edit: func(d *Document) error {
+				return d.AddCommand("pre-commit", HookCommand{Run: "make fmt", Description: "Format"})
+			},
+			want: strings.Replace(original, "      run: npm test\n", "      run: npm test\n    - run: make fmt\n      description: Format\n", 1),
+		},
+		{
+			name: "add new hook",
+			edit: func(d *Document) error { return d.AddCommand("pre-push", HookCommand{Run: "make check"}) },
+			want: strings.Replace(original, "    - run: ./check.sh\n", "    - run: ./check.sh\n  pre-push:\n    - run: make check\n", 1),
+		},
+		{
+			name: "remove with comment",
+			edit: func(d *Document) error { return d.RemoveCommand("pre-commit", 0) },
+			want: strings.Replace(original, "    # lint first\n    - run: \"npm run lint\"   # fast\n      description: 'Run ESLint'\n", "", 1),
+		},
+		{
+			name: "remove last command of hook",
+			edit: func(d *Document) error { return d.RemoveCommand("commit-msg", 0) },
+			want: strings.Replace(original, "  commit-msg:\n    - run: ./check.sh\n", "", 1),
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			path := filepath.Join(t.TempDir(), ".githooksrc.yml")
+			os.WriteFile(path, []byte(original), 0600)
+
+			doc, err := OpenDocument(path)
+			if err != nil {
+				t.Fatalf("OpenDocument() error = %v", err)
+			}
+			if err := tt.edit(doc); err != nil {
+				t.Fatalf("edit error = %v", err)
+			}
+			if err := doc.Save(); err != nil {
+				t.Fatalf("Save() error = %v", err)
+			}
+
+			data, _ := os.ReadFile(path)
+			if string(data) != tt.want {
+				t.Errorf("file =\n%s\nwant\n%s", data, tt.want)
+			}
+			info, _ := os.Stat(path)
+			if info.Mode().Perm() != 0600 {
+				t.Errorf("mode = %v, want 0600", info.Mode().Perm())
+			}
+			entries, _ := os.ReadDir(filepath.Dir(path))
+			if len(entries) != 1 {
+				t.Errorf("expected only the config file, got %d entries", len(entries))
+			}
+		})
+	}
+}
+
+func TestDocument_AddCommandKeepsFormatting(t *testing.T) {
+	tests := []struct {
+		name     string
+		original string
+		want     string
+	}{
+		{
+			name:     "null hooks",
+			original: "# Project hooks\ntimeout: 30s  # generous\nhooks:  # none yet\n",
+			want:     "# Project hooks\ntimeout: 30s  # generous\nhooks:  # none yet\n  pre-commit:\n    - run: make check\n",
+		},
+		{
+			name:     "explicit null hooks",
+			original: "hooks: ~ # none yet\n# end\n",
+			want:     "hooks: # none yet\n  pre-commit:\n    - run: make check\n# end\n",
+		},
+		{
+			name:     "null list",
+			original: "hooks:\n  pre-commit:\n  # later\n  commit-msg:\n    - run: ./check.sh\n",
+			want:     "hooks:\n  pre-commit:\n    - run: make check\n  # later\n  commit-msg:\n    - run: ./check.sh\n",
+		},
+		{
+			name:     "empty flow list",
+			original: "hooks:\n  pre-commit: []  # none yet\n",
+			want:     "hooks:\n  pre-commit: [{run: make check}]  # none yet\n",
+		},
+		{
+			name:     "flow list",
+			original: "hooks:\n  pre-commit: [{run: 'a, b'}, {run: \"c]\"}]  # two\n",
+			want:     "hooks:\n  pre-commit: [{run: 'a, b'}, {run: \"c]\"}, {run: make check}]  # two\n",
+		},
+		{
+			name:     "multi-line flow list",
+			original: "hooks:\n  pre-commit: [\n    {run: a},  # first\n    {run: b}   # [second]\n  ]\n",
+			want:     "hooks:\n  pre-commit: [\n    {run: a},  # first\n    {run: b}, {run: make check}   # [second]\n  ]\n",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			path := filepath.Join(t.TempDir(), ".githooksrc.yml")
+			os.WriteFile(path, []byte(tt.original), 0644)
+
+			doc, err := OpenDocument(path)
+			if err != nil {
+				t.Fatalf("OpenDocument() error = %v", err)
+			}
+			if err := doc.AddCommand("pre-commit", HookCommand{Run: "make check"}); err != nil {
+				t.Fatalf("AddCommand() error = %v", err)
+			}
+			if err := doc.Save(); err != nil {
+				t.Fatalf("Save() error = %v", err)
+			}
+
+			data, _ := os.ReadFile(path)
+			if string(data) != tt.want {
+				t.Errorf("file =\n%s\nwant\n%s", data, tt.want)
+			}
+			if doc.Rewritten() {
+				t.Error("Rewritten() = true, want the edit spliced in")
+			}
+		})
+	}
+}
+
+func TestDocument_FlowStyle(t *testing.T) {
+	path := filepath.Join(t.TempDir(), ".githooksrc.yml")
+	os.WriteFile(path, []byte("hooks: {pre-commit: [{run: a}]}\n"), 0644)
+
+	doc, err := OpenDocument(path)
+	if err != nil {
+		t.Fatalf("OpenDocument() error = %v", err)
+	}
+	if err := doc.AddCommand("pre-commit", HookCommand{Run: "b"}); err != nil {
+		t.Fatalf("AddCommand() error = %v", err)
+	}
+	if err := doc.SetEnabled("pre-commit", 0, false); err != nil {
+		t.Fatalf("SetEnabled() error = %v", err)
+	}
+	if err := doc.Save(); err != nil {
+		t.Fatalf("Save() error = %v", err)
+	}
+	if !doc.Rewritten() {
+		t.Error("Rewritten() = false, want true for an edit inside a flow mapping")
+	}
+
+	cfg, err := Load(path)
+	if err != nil {
+		t.Fatalf("Load() error = %v", err)
+	}
+	commands := cfg.Hooks["pre-commit"]
+	if len(commands) != 2 || commands[0].IsEnabled() || commands[1].Run != "b" {
+		t.Errorf("commands = %+v", commands)
 	}
-	return tmpfile.Name()
 }
@@ -0,0 +1,626 @@
+package config
+
+import (
+	"bytes"
+	"fmt"
+	"os"
+	"path/filepath"
+	"reflect"
+	"sort"
+	"strings"
+
+	"gopkg.in/yaml.v3"
+)
+
+// Document is a config file opened for editing. Edits change its YAML node
+// tree in place, and Save rewrites only the lines of the entries they
+// touched, so comments, key order, quoting and blank lines elsewhere in
+// the file are kept.
+type Document struct {
+	path  string
+	lines []string // original lines, each with its newline
+	root  yaml.Node
+	cfg   *Config
+
+	splices  []splice
+	reencode bool // an edit could not be spliced; write the whole tree
+}
+
+// splice replaces lines [start, end) of the original file with text.
+type splice struct {
+	start, end int
+	text       string
+}
+
+// OpenDocument reads the config file at path for editing.
+func OpenDocument(path string) (*Document, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read config file: %w", err)
+	}
+	cfg, err := parse(data, path)
+	if err != nil {
+		return nil, err
+	}
+	d := &Document{path: path, lines: strings.SplitAfter(string(data), "\n"), cfg: cfg}
+	if err := yaml.Unmarshal(data, &d.root); err != nil {
+		return nil, fmt.Errorf("failed to parse config file: %w", err)
+	}
+	if d.root.Kind == 0 {
+		// Empty or comment-only file
+		d.root = yaml.Node{Kind: yaml.DocumentNode, Content: []*yaml.Node{{Kind: yaml.MappingNode, Tag: "!!map"}}}
+	}
+	if d.top().Kind != yaml.MappingNode {
+		return nil, fmt.Errorf("failed to parse config file: top level is not a mapping")
+	}
+	return d, nil
+}
+
+// Config returns the config as it was when the document was opened.
+// Indexes into its hook lists identify commands for the edit methods.
+func (d *Document) Config() *Config {
+	return d.cfg
+}
+
+// AddCommand appends a command to the hook's list, creating the list if
+// needed. Empty fields are left out.
+func (d *Document) AddCommand(hookName string, cmd HookCommand) error {
+	item := &yaml.Node{}
+	if err := item.Encode(cmd); err != nil {
+		return fmt.Errorf("failed to encode command: %w", err)
+	}
+	dropEmptyScalars(item)
+
+	hooksKey, hooks := mappingValue(d.top(), "hooks")
+	if hooks == nil || isNull(hooks) {
+		list := &yaml.Node{Kind: yaml.SequenceNode, Tag: "!!seq", Content: []*yaml.Node{item}}
+		newHooks := &yaml.Node{Kind: yaml.MappingNode, Tag: "!!map", Content: []*yaml.Node{scalar(hookName), list}}
+		if hooks == nil {
+			d.top().Content = append(d.top().Content, scalar("hooks"), newHooks)
+			text, err := renderItem(item, 4)
+			if err != nil {
+				return err
+			}
+			d.appendText("hooks:\n  " + hookName + ":\n" + text)
+			return nil
+		}
+		null := *hooks
+		*hooks = *newHooks
+		indent := hooksKey.Column + 1
+		text, err := renderItem(item, indent+2)
+		if err != nil {
+			return err
+		}
+		d.spliceNullValue(hooksKey, &null, strings.Repeat(" ", indent)+hookName+":\n"+text)
+		return nil
+	}
+	if hooks.Kind != yaml.MappingNode {
+		return fmt.Errorf("'hooks' is not a mapping")
+	}
+
+	key, list := mappingValue(hooks, hookName)
+	if list == nil {
+		list = &yaml.Node{Kind: yaml.SequenceNode, Tag: "!!seq", Content: []*yaml.Node{item}}
+		hooks.Content = append(hooks.Content, scalar(hookName), list)
+		d.spliceNewHook(hooksKey, hooks, hookName, item)
+		return nil
+	}
+	if isNull(list) {
+		null := *list
+		*list = yaml.Node{Kind: yaml.SequenceNode, Tag: "!!seq", Content: []*yaml.Node{item}}
+		if hooks.Style&yaml.FlowStyle != 0 {
+			d.reencode = true
+			return nil
+		}
+		text, err := renderItem(item, key.Column+1)
+		if err != nil {
+			return err
+		}
+		d.spliceNullValue(key, &null, text)
+		return nil
+	}
+	if list.Kind != yaml.SequenceNode {
+		return fmt.Errorf("hook %q is not a list", hookName)
+	}
+	list.Content = append(list.Content, item)
+
+	if list.Style&yaml.FlowStyle != 0 {
+		d.spliceFlowItem(list, item)
+		return nil
+	}
+	last := list.Content[len(list.Content)-2]
+	_, end, indent, ok := d.itemRange(last)
+	if !ok {
+		d.reencode = true
+		return nil
+	}
+	text, err := renderItem(item, indent)
+	if err != nil {
+		return err
+	}
+	d.splices = append(d.splices, splice{start: end, end: end, text: text})
+	return nil
+}
+
+// RemoveCommand removes the command at index from the hook's list, and
+// the hook itself when no command is left.
+func (d *Document) RemoveCommand(hookName string, index int) error {
+	hooks, list, item, err := d.command(hookName, index)
+	if err != nil {
+		return err
+	}
+	list.Content = append(list.Content[:index], list.Content[index+1:]...)
+
+	if len(list.Content) > 0 {
+		start, end, _, ok := d.itemRange(item)
+		if !ok {
+			d.reencode = true
+			return nil
+		}
+		if item.HeadComment != "" {
+			// The comment directly above the item goes with it
+			for start > 0 && strings.HasPrefix(strings.TrimSpace(d.lines[start-1]), "#") {
+				start--
+			}
+		}
+		d.splices = append(d.splices, splice{start: start, end: end})
+		return nil
+	}
+
+	// Remove the hook key along with its now empty list
+	for i := 0; i+1 < len(hooks.Content); i += 2 {
+		if hooks.Content[i].Value == hookName {
+			key := hooks.Content[i]
+			hooks.Content = append(hooks.Content[:i], hooks.Content[i+2:]...)
+			if hooks.Style&yaml.FlowStyle != 0 {
+				d.reencode = true
+				return nil
+			}
+			start := key.Line - 1
+			end := d.blockEnd(start, key.Column-1)
+			// Earlier removals from the list are covered by this one
+			kept := d.splices[:0]
+			for _, s := range d.splices {
+				if s.start < start || s.end > end {
+					kept = append(kept, s)
+				}
+			}
+			d.splices = append(kept, splice{start: start, end: end})
+			return nil
+		}
+	}
+	return nil
+}
+
+// SetEnabled sets whether the command at index in the hook's list is
+// enabled. Enabling removes the enabled key, since that is the default.
+func (d *Document) SetEnabled(hookName string, index int, enabled bool) error {
+	_, _, item, err := d.command(hookName, index)
+	if err != nil {
+		return err
+	}
+	if item.Kind != yaml.MappingNode {
+		return fmt.Errorf("hook %q command #%d is not a mapping", hookName, index+1)
+	}
+
+	keyIndex := -1
+	for i := 0; i+1 < len(item.Content); i += 2 {
+		if item.Content[i].Value == "enabled" {
+			keyIndex = i
+		}
+	}
+	switch {
+	case enabled && keyIndex >= 0:
+		item.Content = append(item.Content[:keyIndex], item.Content[keyIndex+2:]...)
+	case !enabled && keyIndex >= 0:
+		value := item.Content[keyIndex+1]
+		value.Kind, value.Tag, value.Value, value.Style = yaml.ScalarNode, "!!bool", "false", 0
+	case !enabled:
+		item.Content = append(item.Content, scalar("enabled"), &yaml.Node{Kind: yaml.ScalarNode, Tag: "!!bool", Value: "false"})
+	default:
+		return nil
+	}
+
+	start, end, indent, ok := d.itemRange(item)
+	if !ok || item.Style&yaml.FlowStyle != 0 {
+		d.reencode = true
+		return nil
+	}
+	text, err := renderItem(item, indent)
+	if err != nil {
+		return err
+	}
+	d.splices = append(d.splices, splice{start: start, end: end, text: text})
+	return nil
+}
+
+// Save writes the edited document back to its file atomically. If the
+// edited lines do not decode to the edited tree, the whole tree is written
+// instead; Rewritten reports when that happened.
+func (d *Document) Save() error {
+	data, err := d.render()
+	if err != nil {
+		return err
+	}
+	if err := writeFileAtomic(d.path, data); err != nil {
+		return fmt.Errorf("failed to write config file: %w", err)
+	}
+	return nil
+}
+
+// Rewritten reports whether the last Save wrote the whole tree rather than
+// splicing the edits into the original lines, which can lose comments and
+// formatting.
+func (d *Document) Rewritten() bool {
+	return d.reencode
+}
+
+// render returns the edited file contents.
+func (d *Document) render() ([]byte, error) {
+	tree, err := encodeNode(&d.root)
+	if err != nil {
+		return nil, err
+	}
+	if d.reencode {
+		return tree, nil
+	}
+
+	sort.Slice(d.splices, func(i, j int) bool { return d.splices[i].start > d.splices[j].start })
+	lines := append([]string(nil), d.lines...)
+	for _, s := range d.splices {
+		lines = append(lines[:s.start], append([]string{s.text}, lines[s.end:]...)...)
+	}
+	spliced := []byte(strings.Join(lines, ""))
+
+	// Guard against a splice that changed more than intended
+	var want, got Config
+	if err := yaml.Unmarshal(tree, &want); err != nil {
+		return nil, fmt.Errorf("failed to encode config: %w", err)
+	}
+	if err := yaml.Unmarshal(spliced, &got); err != nil || !reflect.DeepEqual(want, got) {
+		d.reencode = true
+		return tree, nil
+	}
+	return spliced, nil
+}
+
+// top returns the top-level mapping.
+func (d *Document) top() *yaml.Node {
+	return d.root.Content[0]
+}
+
+// command returns the hooks mapping, the hook's list and the command at
+// index in it.
+func (d *Document) command(hookName string, index int) (hooks, list, item *yaml.Node, err error) {
+	_, hooks = mappingValue(d.top(), "hooks")
+	if hooks != nil && hooks.Kind == yaml.MappingNode {
+		_, list = mappingValue(hooks, hookName)
+	}
+	if list == nil || list.Kind != yaml.SequenceNode || index < 0 || index >= len(list.Content) {
+		return nil, nil, nil, fmt.Errorf("hook %q has no command #%d", hookName, index+1)
+	}
+	return hooks, list, list.Content[index], nil
+}
+
+// spliceNewHook inserts a new hook key with a one-command list at the end
+// of the block-style hooks mapping.
+func (d *Document) spliceNewHook(hooksKey, hooks *yaml.Node, hookName string, item *yaml.Node) {
+	if hooks.Style&yaml.FlowStyle != 0 || len(hooks.Content) < 4 {
+		d.reencode = true
+		return
+	}
+	// Indent like the first hook and its list
+	firstKey, firstList := hooks.Content[0], hooks.Content[1]
+	keyIndent := firstKey.Column - 1
+	listIndent := keyIndent + 2
+	if firstList.Kind == yaml.SequenceNode && len(firstList.Content) > 0 {
+		if _, _, indent, ok := d.itemRange(firstList.Content[0]); ok {
+			listIndent = indent
+		}
+	}
+	text, err := renderItem(item, listIndent)
+	if err != nil {
+		d.reencode = true
+		return
+	}
+	end := d.blockEnd(hooksKey.Line-1, hooksKey.Column-1)
+	d.splices = append(d.splices, splice{start: end, end: end, text: strings.Repeat(" ", keyIndent) + hookName + ":\n" + text})
+}
+
+// spliceNullValue gives a key whose value was null the block text below
+// it. The null is taken off the key's line, or off its own line after it,
+// and the rest of the key's line, such as a comment, is kept.
+func (d *Document) spliceNullValue(key, null *yaml.Node, text string) {
+	start, end := key.Line-1, key.Line
+	if start < 0 || end > len(d.lines) {
+		d.reencode = true
+		return
+	}
+	line := d.lines[start]
+	if null.Value != "" {
+		// An implicit null has no token to take off
+		switch {
+		case null.Line == key.Line:
+			i := byteOffset(line, null.Column-1)
+			if !strings.HasPrefix(line[i:], null.Value) {
+				d.reencode = true
+				return
+			}
+			line = strings.TrimRight(line[:i], " ") + line[i+len(null.Value):]
+		case null.Line > key.Line && null.Line <= len(d.lines):
+			end = null.Line
+		}
+	}
+	if !strings.HasSuffix(line, "\n") {
+		line += "\n"
+	}
+	d.splices = append(d.splices, splice{start: start, end: end, text: line + text})
+}
+
+// spliceFlowItem writes item, the last of the flow-style list, in flow
+// style after the list's other items, keeping the lines around it.
+func (d *Document) spliceFlowItem(list, item *yaml.Node) {
+	start := list.Line - 1
+	if start < 0 || start >= len(d.lines) {
+		d.reencode = true
+		return
+	}
+	lines := append([]string(nil), d.lines[start:]...)
+	open := byteOffset(lines[0], list.Column-1)
+	closeLine, closeAt, lastLine, lastAt, ok := flowEnd(lines, open)
+	if !ok {
+		d.reencode = true
+		return
+	}
+	for _, s := range d.splices {
+		if s.start <= start+closeLine && s.end > start {
+			d.reencode = true // overlaps an earlier edit
+			return
+		}
+	}
+	text, err := renderFlowItem(item)
+	if err != nil {
+		d.reencode = true
+		return
+	}
+
+	line, at := closeLine, closeAt
+	switch {
+	case len(list.Content) == 1:
+		// The list was empty
+	case lines[lastLine][lastAt-1] == ',':
+		line, at, text = lastLine, lastAt, " "+text
+	default:
+		line, at, text = lastLine, lastAt, ", "+text
+	}
+	lines[line] = lines[line][:at] + text + lines[line][at:]
+	d.splices = append(d.splices, splice{start: start, end: start + closeLine + 1, text: strings.Join(lines[:closeLine+1], "")})
+}
+
+// flowEnd finds the bracket closing the flow collection opened at byte
+// open of lines[0], and the end of the last content before it. Quoted
+// strings and comments are skipped.
+func flowEnd(lines []string, open int) (closeLine, closeAt, lastLine, lastAt int, ok bool) {
+	if open >= len(lines[0]) || lines[0][open] != '[' {
+		return 0, 0, 0, 0, false
+	}
+	depth := 0
+	var quote byte
+	var prev byte = ' ' // last character outside quotes and comments
+	for i, line := range lines {
+		from := 0
+		if i == 0 {
+			from = open
+		}
+		for j := from; j < len(line); j++ {
+			c := line[j]
+			switch {
+			case quote != 0:
+				if c == '\\' && quote == '"' {
+					j++
+				} else if c == quote {
+					quote = 0
+					lastLine, lastAt = i, j+1
+				}
+				continue
+			case c == '#' && (j == 0 || line[j-1] == ' ' || line[j-1] == '\t'):
+				j = len(line) // comment to the end of the line
+				continue
+			case c == '\'' || c == '"':
+				if strings.IndexByte("[{,: \t\r\n", prev) >= 0 {
+					quote = c
+				}
+			case c == '[' || c == '{':
+				depth++
+			case c == ']' || c == '}':
+				depth--
+				if depth == 0 {
+					return i, j, lastLine, lastAt, true
+				}
+			}
+			if c != ' ' && c != '\t' && c != '\r' && c != '\n' {
+				if depth > 1 || c != '[' || i != 0 || j != open {
+					lastLine, lastAt = i, j+1
+				}
+			}
+			prev = c
+		}
+		prev = '\n'
+	}
+	return 0, 0, 0, 0, false
+}
+
+// appendText adds text at the end of the file, on a line of its own.
+func (d *Document) appendText(text string) {
+	n := len(d.lines)
+	prefix := ""
+	if last := d.lines[n-1]; last != "" && !strings.HasSuffix(last, "\n") {
+		prefix = "\n"
+	}
+	d.splices = append(d.splices, splice{start: n, end: n, text: prefix + text})
+}
+
+// itemRange returns the lines [start, end) of a block sequence item and
+// the indentation of its dash. Trailing blank lines and comments that are
+// not indented past the dash are left out, as they belong to what follows.
+func (d *Document) itemRange(item *yaml.Node) (start, end, indent int, ok bool) {
+	start = item.Line - 1
+	if start < 0 || start >= len(d.lines) {
+		return 0, 0, 0, false
+	}
+	line := d.lines[start]
+	dash := strings.LastIndex(line[:min(item.Column-1, len(line))], "-")
+	if dash < 0 || strings.TrimSpace(line[:dash]) != "" {
+		return 0, 0, 0, false // not on the dash's line
+	}
+	return start, d.blockEnd(start, dash), dash, true
+}
+
+// blockEnd returns the end of the block starting at line start whose
+// first line is indented by indent: the line after the last content line
+// indented deeper. Comments indented deeper also belong to the block.
+func (d *Document) blockEnd(start, indent int) int {
+	end := start + 1
+	for i := start + 1; i < len(d.lines); i++ {
+		text := strings.TrimRight(d.lines[i], "\r\n")
+		trimmed := strings.TrimLeft(text, " ")
+		if trimmed == "" {
+			continue
+		}
+		if len(text)-len(trimmed) <= indent {
+			if strings.HasPrefix(trimmed, "#") {
+				continue
+			}
+			break
+		}
+		end = i + 1
+	}
+	return end
+}
+
+// renderItem encodes a sequence item as block YAML indented by indent,
+// without the comments around it.
+func renderItem(item *yaml.Node, indent int) (string, error) {
+	c := *item
+	c.HeadComment, c.FootComment = "", ""
+	data, err := encodeNode(&yaml.Node{Kind: yaml.SequenceNode, Content: []*yaml.Node{&c}})
+	if err != nil {
+		return "", err
+	}
+	pad := strings.Repeat(" ", indent)
+	var b strings.Builder
+	for _, line := range strings.SplitAfter(string(data), "\n") {
+		if line != "" {
+			b.WriteString(pad + line)
+		}
+	}
+	return b.String(), nil
+}
+
+// renderFlowItem encodes a sequence item as flow YAML on one line, without
+// the comments around it.
+func renderFlowItem(item *yaml.Node) (string, error) {
+	c := *item
+	c.HeadComment, c.LineComment, c.FootComment = "", "", ""
+	c.Style |= yaml.FlowStyle
+	data, err := encodeNode(&c)
+	if err != nil {
+		return "", err
+	}
+	return strings.TrimSuffix(string(data), "\n"), nil
+}
+
+// byteOffset returns the byte offset in line of the character at index
+// column, as yaml counts columns in characters.
+func byteOffset(line string, column int) int {
+	for i := range line {
+		if column == 0 {
+			return i
+		}
+		column--
+	}
+	return len(line)
+}
+
+// encodeNode encodes a node as YAML with two-space indentation.
+func encodeNode(node *yaml.Node) ([]byte, error) {
+	var buf bytes.Buffer
+	enc := yaml.NewEncoder(&buf)
+	enc.SetIndent(2)
+	if err := enc.Encode(node); err != nil {
+		return nil, fmt.Errorf("failed to encode config: %w", err)
+	}
+	if err := enc.Close(); err != nil {
+		return nil, fmt.Errorf("failed to encode config: %w", err)
+	}
+	return buf.Bytes(), nil
+}
+
+// mappingValue returns the key and value nodes for key in a mapping, or
+// nils.
+func mappingValue(m *yaml.Node, key string) (*yaml.Node, *yaml.Node) {
+	if m == nil || m.Kind != yaml.MappingNode {
+		return nil, nil
+	}
+	for i := 0; i+1 < len(m.Content); i += 2 {
+		if m.Content[i].Value == key {
+			return m.Content[i], m.Content[i+1]
+		}
+	}
+	return nil, nil
+}
+
+// dropEmptyScalars removes the keys of a mapping whose values are empty
+// strings.
+func dropEmptyScalars(m *yaml.Node) {
+	var kept []*yaml.Node
+	for i := 0; i+1 < len(m.Content); i += 2 {
+		if v := m.Content[i+1]; v.Kind == yaml.ScalarNode && v.Tag == "!!str" && v.Value == "" {
+			continue
+		}
+		kept = append(kept, m.Content[i], m.Content[i+1])
+	}
+	m.Content = kept
+}
+
+func isNull(n *yaml.Node) bool {
+	return n.Kind == yaml.ScalarNode && n.Tag == "!!null"
+}
+
+func scalar(value string) *yaml.Node {
+	return &yaml.Node{Kind: yaml.ScalarNode, Tag: "!!str", Value: value}
+}
+
+// writeFileAtomic replaces the file at path with data through a temporary
+// file in the same directory, so readers never see a partial file. The
+// file keeps its permissions.
+func writeFileAtomic(path string, data []byte) error {
+	mode := os.FileMode(0644)
+	if info, err := os.Stat(path); err == nil {
+		mode = info.Mode().Perm()
+	}
+	tmp, err := os.CreateTemp(filepath.Dir(path), "."+filepath.Base(path)+".*.tmp")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(tmp.Name()) // no-op after the rename
+
+	if _, err := tmp.Write(data); err != nil {
+		tmp.Close()
+		return err
+	}
+	if err := tmp.Sync(); err != nil {
+		tmp.Close()
+		return err
+	}
+	if err := tmp.Close(); err != nil {
+		return err
+	}
+	if err := os.Chmod(tmp.Name(), mode); err != nil {
+		return err
+	}
+	if err := os.Rename(tmp.Name(), path); err != nil {
+		return err
+	}
+	return nil
+}
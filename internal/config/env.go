@@ -0,0 +1,156 @@
+package config
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strings"
+)
+
+// EnvVar is an environment variable set for a command. Unless Literal is
+// set, ${NAME} references in Value are expanded when the command runs.
+type EnvVar struct {
+	Name    string
+	Value   string
+	Literal bool // single-quoted in an env file; not expanded
+}
+
+// envRefPattern matches a ${NAME} reference in an env value.
+var envRefPattern = regexp.MustCompile(`\$\{([A-Za-z_][A-Za-z0-9_]*)\}`)
+
+// ExpandEnv replaces each ${NAME} in s with the value lookup returns for
+// NAME, or with the empty string if it is not set.
+func ExpandEnv(s string, lookup func(string) (string, bool)) string {
+	return envRefPattern.ReplaceAllStringFunc(s, func(ref string) string {
+		value, _ := lookup(ref[2 : len(ref)-1])
+		return value
+	})
+}
+
+// resolveEnv validates a command's env map and returns it as a list sorted
+// by name.
+func resolveEnv(env map[string]string) ([]EnvVar, []error) {
+	var errs []error
+	var vars []EnvVar
+	for name, value := range env {
+		if !envNamePattern.MatchString(name) {
+			errs = append(errs, fmt.Errorf("invalid env name %q: must contain only letters, digits and '_' and not start with a digit", name))
+			continue
+		}
+		vars = append(vars, EnvVar{Name: name, Value: value})
+	}
+	sort.Slice(vars, func(i, j int) bool { return vars[i].Name < vars[j].Name })
+	sort.Slice(errs, func(i, j int) bool { return errs[i].Error() < errs[j].Error() })
+	return vars, errs
+}
+
+// validateRepoPath checks that a path from the config is relative and stays
+// inside the repository.
+func validateRepoPath(field, p string) error {
+	if !filepath.IsLocal(filepath.FromSlash(p)) {
+		return fmt.Errorf("invalid %s %q: must be a relative path inside the repository", field, p)
+	}
+	return nil
+}
+
+// cleanRepoPath returns a validated repository path in clean, slash-separated
+// form, or "" for the repository root.
+func cleanRepoPath(p string) string {
+	p = filepath.ToSlash(filepath.Clean(filepath.FromSlash(p)))
+	if p == "." {
+		return ""
+	}
+	return p
+}
+
+// ReadEnvFile reads a dotenv file. Each non-blank line that does not start
+// with '#' has the form NAME=value, optionally preceded by "export ".
+// Values may be single-quoted (taken literally) or double-quoted (with \n,
+// \t, \" and \\ escapes); unquoted values end at " #".
+func ReadEnvFile(path string) ([]EnvVar, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	vars, err := parseEnvFile(f)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", path, err)
+	}
+	return vars, nil
+}
+
+// parseEnvFile parses the contents of a dotenv file.
+func parseEnvFile(r io.Reader) ([]EnvVar, error) {
+	var vars []EnvVar
+	scanner := bufio.NewScanner(r)
+	for n := 1; scanner.Scan(); n++ {
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+		line = strings.TrimPrefix(line, "export ")
+
+		name, value, ok := strings.Cut(line, "=")
+		name = strings.TrimSpace(name)
+		if !ok || !envNamePattern.MatchString(name) {
+			return nil, fmt.Errorf("line %d: expected NAME=value", n)
+		}
+
+		v, err := parseEnvValue(strings.TrimSpace(value))
+		if err != nil {
+			return nil, fmt.Errorf("line %d: %w", n, err)
+		}
+		v.Name = name
+		vars = append(vars, v)
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, err
+	}
+	return vars, nil
+}
+
+// parseEnvValue unquotes a single dotenv value.
+func parseEnvValue(s string) (EnvVar, error) {
+	switch {
+	case strings.HasPrefix(s, "'"):
+		end := strings.Index(s[1:], "'")
+		if end < 0 {
+			return EnvVar{}, fmt.Errorf("unterminated single-quoted value")
+		}
+		return EnvVar{Value: s[1 : end+1], Literal: true}, nil
+
+	case strings.HasPrefix(s, `"`):
+		var b strings.Builder
+		for i := 1; i < len(s); i++ {
+			switch c := s[i]; {
+			case c == '"':
+				return EnvVar{Value: b.String()}, nil
+			case c == '\\' && i+1 < len(s):
+				i++
+				switch s[i] {
+				case 'n':
+					b.WriteByte('\n')
+				case 't':
+					b.WriteByte('\t')
+				default:
+					b.WriteByte(s[i])
+				}
+			default:
+				b.WriteByte(c)
+			}
+		}
+		return EnvVar{}, fmt.Errorf("unterminated double-quoted value")
+
+	default:
+		if i := strings.Index(s, " #"); i >= 0 {
+			s = s[:i]
+		}
+		return EnvVar{Value: strings.TrimSpace(s)}, nil
+	}
+}
@@ -0,0 +1,319 @@
+package config
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strings"
+
+	"githookd/internal/git"
+
+	"gopkg.in/yaml.v3"
+)
+
+// Extend names a config to merge beneath the config that lists it, with
+// the same rules as a lower layer. It is either a file, relative to the
+// extending file, or a file inside a git repository at a pinned ref.
+//
+// In YAML, a plain string is a file path:
+//
+//	extends:
+//	  - ../shared/githooks.yml
+//	  - repo: git@github.com:acme/hooks.git
+//	    ref: v1.4.0
+//	    path: base.yml
+type Extend struct {
+	Path string `yaml:"path"`
+	Repo string `yaml:"repo,omitempty"` // local path or URL of a git repository
+	Ref  string `yaml:"ref,omitempty"`  // branch, tag or commit; required with repo
+}
+
+// UnmarshalYAML implements yaml.Unmarshaler.
+func (e *Extend) UnmarshalYAML(node *yaml.Node) error {
+	if node.Kind == yaml.ScalarNode {
+		*e = Extend{Path: node.Value}
+		return nil
+	}
+	type plain Extend
+	return node.Decode((*plain)(e))
+}
+
+// MarshalYAML implements yaml.Marshaler.
+func (e Extend) MarshalYAML() (interface{}, error) {
+	if e.Repo == "" && e.Ref == "" {
+		return e.Path, nil
+	}
+	type plain Extend
+	return plain(e), nil
+}
+
+// Extends is a list of extended configs. A single entry may be written
+// without the list.
+type Extends []Extend
+
+// UnmarshalYAML implements yaml.Unmarshaler.
+func (l *Extends) UnmarshalYAML(node *yaml.Node) error {
+	if node.Kind != yaml.SequenceNode {
+		var e Extend
+		if err := node.Decode(&e); err != nil {
+			return err
+		}
+		*l = Extends{e}
+		return nil
+	}
+	var list []Extend
+	if err := node.Decode(&list); err != nil {
+		return err
+	}
+	*l = list
+	return nil
+}
+
+// LoadOptions controls how LoadLayered reads configs from git repositories.
+type LoadOptions struct {
+	// CachePath is the file caching configs read from git repositories,
+	// normally inside the git directory. Empty disables the cache.
+	CachePath string
+}
+
+// ExtendUpdate reports the commit a pinned ref resolved to before and
+// after UpdateExtends.
+type ExtendUpdate struct {
+	Source string // repo@ref:path
+	From   string // commit; empty if the config was not cached
+	To     string
+}
+
+// UpdateExtends reads every config extended from a git repository again,
+// so that refs such as branches pick up new commits, and rewrites the
+// cache. It returns the refreshed configs sorted by source.
+func UpdateExtends(path string, opts LoadOptions) ([]ExtendUpdate, error) {
+	l := newLoader(opts)
+	l.refresh = true
+	cfg, err := l.load(path)
+	if err != nil {
+		return nil, err
+	}
+	if len(cfg.errs) > 0 {
+		return nil, cfg.errs[0]
+	}
+	sort.Slice(l.updates, func(i, j int) bool { return l.updates[i].Source < l.updates[j].Source })
+	return l.updates, l.saveCache()
+}
+
+// source is where a config file is read from.
+type source struct {
+	repo string // git repository; empty for a file on disk
+	ref  string
+	path string // inside repo when repo is set
+}
+
+func (s source) String() string {
+	if s.repo == "" {
+		return s.path
+	}
+	return fmt.Sprintf("%s@%s:%s", s.repo, s.ref, s.path)
+}
+
+// extend returns the source an extends entry of s refers to. Plain paths
+// are relative to s, and stay in its repository and ref.
+func (s source) extend(e Extend) (source, error) {
+	if e.Path == "" {
+		return source{}, fmt.Errorf("extends: 'path' is required")
+	}
+	if e.Repo == "" {
+		if e.Ref != "" {
+			return source{}, fmt.Errorf("extends %s: 'ref' requires 'repo'", e.Path)
+		}
+		if s.repo != "" {
+			return source{repo: s.repo, ref: s.ref, path: path.Join(path.Dir(s.path), filepath.ToSlash(e.Path))}, nil
+		}
+		p := e.Path
+		if !filepath.IsAbs(p) {
+			p = filepath.Join(filepath.Dir(s.path), p)
+		}
+		return source{path: p}, nil
+	}
+
+	if e.Ref == "" {
+		return source{}, fmt.Errorf("extends %s: 'ref' is required to pin repository %s", e.Path, e.Repo)
+	}
+	repo := e.Repo
+	if !isRemote(repo) && !filepath.IsAbs(repo) {
+		if s.repo != "" {
+			return source{}, fmt.Errorf("extends %s: repository %s must be absolute or a URL in a config from a git repository", e.Path, repo)
+		}
+		repo = filepath.Join(filepath.Dir(s.path), repo)
+	}
+	return source{repo: repo, ref: e.Ref, path: path.Clean(filepath.ToSlash(e.Path))}, nil
+}
+
+// scpURLPattern matches scp-like git URLs such as git@host:org/repo.git.
+var scpURLPattern = regexp.MustCompile(`^[A-Za-z0-9._-]+@[^/:]+:`)
+
+// isRemote reports whether repo is a URL rather than a local path.
+func isRemote(repo string) bool {
+	return strings.Contains(repo, "://") || scpURLPattern.MatchString(repo)
+}
+
+// cacheEntry is a config read from a git repository.
+type cacheEntry struct {
+	Commit  string `json:"commit"`
+	Content string `json:"content"`
+}
+
+// loader merges configs and the configs they extend.
+type loader struct {
+	opts    LoadOptions
+	refresh bool // read git sources again instead of using the cache
+
+	cache   map[string]cacheEntry // keyed by source
+	dirty   bool
+	fetched map[string]bool // sources refreshed by this loader
+	merged  map[string]bool // sources already merged by this loader
+	stack   []string        // sources being loaded, to detect cycles
+	errs    []error
+	updates []ExtendUpdate
+}
+
+func newLoader(opts LoadOptions) *loader {
+	return &loader{opts: opts, fetched: make(map[string]bool), merged: make(map[string]bool)}
+}
+
+// layer merges the configs src extends, then src itself, over into. A
+// circular extends is recorded as a validation error and skipped. A config
+// reached a second time, such as one extended by two configs, is merged
+// only the first time, so that its commands are not added twice.
+func (l *loader) layer(src source, into *Config) error {
+	name := src.String()
+	if i := indexOf(l.stack, name); i >= 0 {
+		cycle := append(append([]string(nil), l.stack[i:]...), name)
+		l.errs = append(l.errs, fmt.Errorf("circular extends: %s", strings.Join(cycle, " -> ")))
+		return nil
+	}
+	if l.merged[name] {
+		return nil
+	}
+
+	cfg, err := l.read(src)
+	if err != nil {
+		return fmt.Errorf("%s: %w", name, err)
+	}
+	l.errs = append(l.errs, cfg.errs...)
+
+	l.stack = append(l.stack, name)
+	defer func() { l.stack = l.stack[:len(l.stack)-1] }()
+
+	for _, e := range cfg.Extends {
+		dep, err := src.extend(e)
+		if err != nil {
+			return fmt.Errorf("%s: %w", name, err)
+		}
+		if err := l.layer(dep, into); err != nil {
+			return err
+		}
+	}
+	if err := into.merge(cfg, name); err != nil {
+		return fmt.Errorf("%s: %w", name, err)
+	}
+	l.merged[name] = true
+	return nil
+}
+
+// read parses the config at src. Configs from git repositories come from
+// the cache unless it lacks them or the loader refreshes.
+func (l *loader) read(src source) (*Config, error) {
+	if src.repo == "" {
+		return Load(src.path)
+	}
+
+	key := src.String()
+	if err := l.loadCache(); err != nil {
+		return nil, err
+	}
+	entry, ok := l.cache[key]
+	if !ok || (l.refresh && !l.fetched[key]) {
+		commit, content, err := l.fetch(src)
+		if err != nil {
+			return nil, err
+		}
+		if l.refresh {
+			l.updates = append(l.updates, ExtendUpdate{Source: key, From: entry.Commit, To: commit})
+		}
+		entry = cacheEntry{Commit: commit, Content: string(content)}
+		l.cache[key] = entry
+		l.fetched[key] = true
+		l.dirty = true
+	}
+	return parse([]byte(entry.Content), key)
+}
+
+// fetch reads a config from a git repository. Remote repositories are
+// fetched into a bare repository next to the cache.
+func (l *loader) fetch(src source) (commit string, content []byte, err error) {
+	repo := src.repo
+	if isRemote(repo) {
+		if l.opts.CachePath == "" {
+			return "", nil, fmt.Errorf("fetching %s requires a git repository to cache it in", repo)
+		}
+		sum := sha256.Sum256([]byte(repo))
+		cacheRepo := filepath.Join(filepath.Dir(l.opts.CachePath), "repos", hex.EncodeToString(sum[:8]))
+		commit, err = git.FetchRef(cacheRepo, repo, src.ref)
+		repo = cacheRepo
+	} else {
+		commit, err = git.ResolveCommit(repo, src.ref)
+	}
+	if err != nil {
+		return "", nil, err
+	}
+	content, err = git.ShowFile(repo, commit, src.path)
+	return commit, content, err
+}
+
+// loadCache reads the cache file once. A missing file is an empty cache.
+func (l *loader) loadCache() error {
+	if l.cache != nil {
+		return nil
+	}
+	l.cache = make(map[string]cacheEntry)
+	if l.opts.CachePath == "" {
+		return nil
+	}
+	data, err := os.ReadFile(l.opts.CachePath)
+	if errors.Is(err, os.ErrNotExist) {
+		return nil
+	}
+	if err != nil {
+		return fmt.Errorf("failed to read extends cache: %w", err)
+	}
+	if err := json.Unmarshal(data, &l.cache); err != nil {
+		return fmt.Errorf("failed to parse extends cache %s: %w", l.opts.CachePath, err)
+	}
+	return nil
+}
+
+// saveCache writes the cache file if it changed.
+func (l *loader) saveCache() error {
+	if !l.dirty || l.opts.CachePath == "" {
+		return nil
+	}
+	data, err := json.MarshalIndent(l.cache, "", "  ")
+	if err != nil {
+		return fmt.Errorf("failed to marshal extends cache: %w", err)
+	}
+	if err := os.MkdirAll(filepath.Dir(l.opts.CachePath), 0755); err != nil {
+		return fmt.Errorf("failed to write extends cache: %w", err)
+	}
+	if err := writeFileAtomic(l.opts.CachePath, data); err != nil {
+		return fmt.Errorf("failed to write extends cache: %w", err)
+	}
+	l.dirty = false
+	return nil
+}
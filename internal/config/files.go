@@ -0,0 +1,235 @@
+package config
+
+import (
+	"fmt"
+	"path"
+	"regexp"
+	"sort"
+	"strings"
+
+	"gopkg.in/yaml.v3"
+)
+
+// regexPrefix marks a glob or exclude entry as a regular expression.
+const regexPrefix = "regex:"
+
+// fileTypes maps the names accepted by the 'types' field to file
+// extensions (leading dot) or exact base names.
+var fileTypes = map[string][]string{
+	"c":          {".c", ".h"},
+	"cpp":        {".cc", ".cpp", ".cxx", ".hh", ".hpp", ".hxx"},
+	"css":        {".css", ".scss", ".sass", ".less"},
+	"dockerfile": {"Dockerfile", ".dockerfile"},
+	"go":         {".go"},
+	"html":       {".html", ".htm"},
+	"java":       {".java"},
+	"javascript": {".js", ".jsx", ".mjs", ".cjs"},
+	"json":       {".json"},
+	"kotlin":     {".kt", ".kts"},
+	"make":       {"Makefile", "makefile", "GNUmakefile", ".mk"},
+	"markdown":   {".md", ".markdown"},
+	"python":     {".py", ".pyi"},
+	"ruby":       {".rb"},
+	"rust":       {".rs"},
+	"shell":      {".sh", ".bash", ".zsh"},
+	"sql":        {".sql"},
+	"toml":       {".toml"},
+	"typescript": {".ts", ".tsx", ".mts", ".cts"},
+	"yaml":       {".yml", ".yaml"},
+}
+
+// StringList is a list of strings that may be written in YAML either as a
+// sequence or as a single scalar.
+type StringList []string
+
+// UnmarshalYAML implements yaml.Unmarshaler.
+func (l *StringList) UnmarshalYAML(node *yaml.Node) error {
+	if node.Kind == yaml.ScalarNode {
+		*l = StringList{node.Value}
+		return nil
+	}
+	var list []string
+	if err := node.Decode(&list); err != nil {
+		return err
+	}
+	*l = list
+	return nil
+}
+
+// FileFilter selects the files a command applies to. A file matches when it
+// matches any glob (or there are none), is of one of the types (or there are
+// none), and matches no exclude pattern.
+type FileFilter struct {
+	Glob    []string
+	Exclude []string
+	Types   []string
+
+	include []filePattern
+	exclude []filePattern
+	exts    []string
+}
+
+// filePattern is a compiled glob or regular expression.
+type filePattern struct {
+	glob string
+	re   *regexp.Regexp
+}
+
+// NewFileFilter compiles a FileFilter, returning every invalid pattern and
+// unknown type rather than just the first.
+func NewFileFilter(glob, exclude, types []string) (*FileFilter, []error) {
+	f := &FileFilter{Glob: glob, Exclude: exclude, Types: types}
+	var errs []error
+
+	compile := func(field string, patterns []string) []filePattern {
+		var compiled []filePattern
+		for _, p := range patterns {
+			if expr, ok := strings.CutPrefix(p, regexPrefix); ok {
+				re, err := regexp.Compile(expr)
+				if err != nil {
+					errs = append(errs, fmt.Errorf("invalid %s regex %q: %w", field, expr, err))
+					continue
+				}
+				compiled = append(compiled, filePattern{re: re})
+				continue
+			}
+			if _, err := path.Match(p, ""); err != nil {
+				errs = append(errs, fmt.Errorf("invalid %s pattern %q: %w", field, p, err))
+				continue
+			}
+			compiled = append(compiled, filePattern{glob: strings.TrimPrefix(p, "./")})
+		}
+		return compiled
+	}
+
+	f.include = compile("glob", glob)
+	f.exclude = compile("exclude", exclude)
+
+	for _, t := range types {
+		exts, ok := fileTypes[strings.ToLower(t)]
+		if !ok {
+			msg := fmt.Sprintf("unknown file type %q", t)
+			if suggestion := suggestFileType(t); suggestion != "" {
+				msg += fmt.Sprintf("; did you mean %q?", suggestion)
+			}
+			errs = append(errs, fmt.Errorf("%s", msg))
+			continue
+		}
+		f.exts = append(f.exts, exts...)
+	}
+
+	if len(errs) > 0 {
+		return nil, errs
+	}
+	return f, nil
+}
+
+// Match reports whether the repository-relative, slash-separated path
+// matches the filter.
+func (f *FileFilter) Match(name string) bool {
+	if len(f.include) > 0 && !matchAny(f.include, name) {
+		return false
+	}
+	if len(f.exts) > 0 && !matchType(f.exts, name) {
+		return false
+	}
+	return !matchAny(f.exclude, name)
+}
+
+// Filter returns the subset of files that match the filter, preserving order.
+func (f *FileFilter) Filter(files []string) []string {
+	var matched []string
+	for _, name := range files {
+		if f.Match(name) {
+			matched = append(matched, name)
+		}
+	}
+	return matched
+}
+
+// matchAny reports whether name matches any of the patterns.
+func matchAny(patterns []filePattern, name string) bool {
+	for _, p := range patterns {
+		if p.re != nil {
+			if p.re.MatchString(name) {
+				return true
+			}
+			continue
+		}
+		if MatchGlob(p.glob, name) {
+			return true
+		}
+	}
+	return false
+}
+
+// matchType reports whether name has one of the given extensions or base names.
+func matchType(exts []string, name string) bool {
+	base := path.Base(name)
+	ext := strings.ToLower(path.Ext(base))
+	for _, e := range exts {
+		if e == base || (strings.HasPrefix(e, ".") && e == ext) {
+			return true
+		}
+	}
+	return false
+}
+
+// MatchGlob matches a slash-separated path against a glob pattern. A pattern
+// without a slash matches the base name at any depth; otherwise it is matched
+// segment by segment, with "**" standing for any number of directories.
+func MatchGlob(pattern, name string) bool {
+	if !strings.Contains(pattern, "/") {
+		ok, _ := path.Match(pattern, path.Base(name))
+		return ok
+	}
+	return matchSegments(strings.Split(pattern, "/"), strings.Split(name, "/"))
+}
+
+// matchSegments matches path segments against pattern segments.
+func matchSegments(pattern, name []string) bool {
+	for len(pattern) > 0 {
+		if pattern[0] == "**" {
+			for i := 0; i <= len(name); i++ {
+				if matchSegments(pattern[1:], name[i:]) {
+					return true
+				}
+			}
+			return false
+		}
+		if len(name) == 0 {
+			return false
+		}
+		if ok, _ := path.Match(pattern[0], name[0]); !ok {
+			return false
+		}
+		pattern, name = pattern[1:], name[1:]
+	}
+	return len(name) == 0
+}
+
+// suggestFileType returns the closest known file type name, or an empty
+// string if none is close enough.
+func suggestFileType(name string) string {
+	var names []string
+	for t := range fileTypes {
+		names = append(names, t)
+	}
+	sort.Strings(names)
+
+	bestMatch := ""
+	bestDist := len(name)
+	threshold := 2
+
+	for _, t := range names {
+		if d := levenshtein(strings.ToLower(name), t); d < bestDist {
+			bestDist = d
+			bestMatch = t
+		}
+	}
+
+	if bestDist <= threshold {
+		return bestMatch
+	}
+	return ""
+}
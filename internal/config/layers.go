@@ -0,0 +1,279 @@
+package config
+
+import (
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"reflect"
+	"sort"
+	"strings"
+)
+
+// SystemConfigPath is the machine-wide config layer.
+var SystemConfigPath = "/etc/githookd/config.yml"
+
+// OriginDefault is reported by Origin for values no layer sets.
+const OriginDefault = "(default)"
+
+// Merge strategies for a hook's command list in a higher layer.
+const (
+	MergePatch   = "patch"   // commands with a known id update it; the rest are appended
+	MergeAppend  = "append"  // all commands are appended
+	MergeReplace = "replace" // the list replaces the lower layers' list
+)
+
+// UserConfigPath returns the per-user config layer,
+// $XDG_CONFIG_HOME/githookd/config.yml, falling back to ~/.config. It
+// returns "" when neither is known.
+func UserConfigPath() string {
+	dir := os.Getenv("XDG_CONFIG_HOME")
+	if dir == "" {
+		home, err := os.UserHomeDir()
+		if err != nil {
+			return ""
+		}
+		dir = filepath.Join(home, ".config")
+	}
+	return filepath.Join(dir, "githookd", "config.yml")
+}
+
+// LocalPath returns the uncommitted override for the repository config at
+// path: .githooksrc.yml becomes .githooksrc.local.yml.
+func LocalPath(path string) string {
+	ext := filepath.Ext(path)
+	return strings.TrimSuffix(path, ext) + ".local" + ext
+}
+
+// LayerPaths returns the config files merged for the repository config at
+// path, from lowest to highest precedence: system, user, the repository
+// config and its local override.
+func LayerPaths(path string) []string {
+	var paths []string
+	if SystemConfigPath != "" {
+		paths = append(paths, SystemConfigPath)
+	}
+	if user := UserConfigPath(); user != "" {
+		paths = append(paths, user)
+	}
+	return append(paths, path, LocalPath(path))
+}
+
+// LoadLayered loads the repository config at path and merges the other
+// layers from LayerPaths over it in order. Each layer is preceded by the
+// configs it extends. The repository config must exist; missing layers are
+// skipped. Scripts resolve relative to the repository config.
+func LoadLayered(path string, opts LoadOptions) (*Config, error) {
+	l := newLoader(opts)
+	merged, err := l.load(path)
+	if err != nil {
+		return nil, err
+	}
+	return merged, l.saveCache()
+}
+
+// load merges every layer for the repository config at path.
+func (l *loader) load(path string) (*Config, error) {
+	merged := &Config{}
+	for _, p := range LayerPaths(path) {
+		if p != path {
+			if _, err := os.Stat(p); errors.Is(err, fs.ErrNotExist) {
+				continue
+			}
+		}
+		if err := l.layer(source{path: p}, merged); err != nil {
+			return nil, err
+		}
+	}
+	merged.dir = filepath.Dir(path)
+	merged.errs = l.errs
+	return merged, nil
+}
+
+// merge applies a higher layer loaded from origin over c. Top-level values
+// the layer sets replace those of c. Hook lists merge according to the
+// layer's merge strategy for the hook, and chains replace chains of the
+// same name.
+func (c *Config) merge(layer *Config, origin string) error {
+	if c.origins == nil {
+		c.origins = make(map[string]Position)
+	}
+	patchFields(reflect.ValueOf(c).Elem(), reflect.ValueOf(layer).Elem(), c.origins, layer.origins, origin)
+	for key, pos := range layer.origins {
+		if strings.HasPrefix(key, "hooks.") || strings.HasPrefix(key, "chains.") {
+			c.origins[key] = pos
+		}
+	}
+
+	var hookNames []string
+	for name := range layer.Merge {
+		hookNames = append(hookNames, name)
+	}
+	sort.Strings(hookNames)
+	for _, name := range hookNames {
+		switch layer.Merge[name] {
+		case MergePatch, MergeAppend, MergeReplace:
+		default:
+			return fmt.Errorf("merge: invalid strategy %q for hook %q: must be %s, %s or %s", layer.Merge[name], name, MergePatch, MergeAppend, MergeReplace)
+		}
+	}
+
+	for name, commands := range layer.Hooks {
+		if c.Hooks == nil {
+			c.Hooks = make(map[string][]HookCommand)
+		}
+		c.Hooks[name] = mergeCommands(c.Hooks[name], commands, layer.Merge[name], origin)
+	}
+	for name, commands := range layer.Chains {
+		if c.Chains == nil {
+			c.Chains = make(map[string][]HookCommand)
+		}
+		c.Chains[name] = mergeCommands(nil, commands, MergeReplace, origin)
+	}
+	return nil
+}
+
+// mergeCommands merges a higher layer's command list over base.
+func mergeCommands(base, commands []HookCommand, strategy, origin string) []HookCommand {
+	if strategy == MergeReplace {
+		base = nil
+	}
+	merged := append([]HookCommand(nil), base...)
+	for _, cmd := range commands {
+		if strategy != MergeAppend && cmd.ID != "" {
+			if i := commandIndex(merged, cmd.ID); i >= 0 {
+				// The id only selects the command; keep its origin
+				cmd.ID, cmd.origins = "", withoutKey(cmd.origins, "id")
+				merged[i] = patchCommand(merged[i], cmd, origin)
+				continue
+			}
+		}
+		merged = append(merged, patchCommand(HookCommand{}, cmd, origin))
+	}
+	return merged
+}
+
+// patchCommand returns base with the fields cmd sets replaced, and env
+// entries added.
+func patchCommand(base, cmd HookCommand, origin string) HookCommand {
+	origins := make(map[string]Position, len(base.origins)+1)
+	for k, v := range base.origins {
+		origins[k] = v
+	}
+	if _, ok := origins[""]; !ok {
+		origins[""] = positionOf(cmd.origins, "", origin)
+	}
+	patchFields(reflect.ValueOf(&base).Elem(), reflect.ValueOf(cmd), origins, cmd.origins, origin)
+	base.origins = origins
+	return base
+}
+
+// withoutKey returns a copy of positions without key.
+func withoutKey(positions map[string]Position, key string) map[string]Position {
+	if positions == nil {
+		return nil
+	}
+	copied := make(map[string]Position, len(positions))
+	for k, v := range positions {
+		if k != key {
+			copied[k] = v
+		}
+	}
+	return copied
+}
+
+// positionOf returns the recorded position of key, or just the file when
+// there is none, as for configs that were not parsed from YAML.
+func positionOf(positions map[string]Position, key, file string) Position {
+	if pos, ok := positions[key]; ok {
+		return pos
+	}
+	return Position{File: file}
+}
+
+// patchFields copies every exported field that src sets over dst and
+// records where it was set, from srcOrigins or else the file origin, under
+// its yaml key. A field is set when srcOrigins records its key, so that a
+// layer can set false or 0 over a lower layer's value; without recorded
+// origins, as for configs not parsed from YAML, non-zero fields are set.
+// Maps are merged key by key, with each key recorded as "<key>.<map key>".
+// Hooks and chains are left to the caller.
+func patchFields(dst, src reflect.Value, origins, srcOrigins map[string]Position, origin string) {
+	t := src.Type()
+	for i := 0; i < t.NumField(); i++ {
+		f := t.Field(i)
+		key := yamlKey(f)
+		if key == "" || key == "hooks" || key == "chains" || key == "merge" || key == "extends" {
+			continue
+		}
+		sv := src.Field(i)
+		set := !sv.IsZero()
+		if _, ok := srcOrigins[key]; ok && sv.Kind() != reflect.Map {
+			set = true
+		}
+		if !set {
+			continue
+		}
+		dv := dst.Field(i)
+		if sv.Kind() == reflect.Map {
+			if dv.IsNil() {
+				dv.Set(reflect.MakeMap(sv.Type()))
+			} else {
+				// Copy so the lower layer's map is left untouched
+				m := reflect.MakeMap(sv.Type())
+				for _, k := range dv.MapKeys() {
+					m.SetMapIndex(k, dv.MapIndex(k))
+				}
+				dv.Set(m)
+			}
+			for _, k := range sv.MapKeys() {
+				dv.SetMapIndex(k, sv.MapIndex(k))
+				origins[key+"."+k.String()] = positionOf(srcOrigins, key+"."+k.String(), origin)
+			}
+			continue
+		}
+		dv.Set(sv)
+		origins[key] = positionOf(srcOrigins, key, origin)
+	}
+}
+
+// yamlKey returns the yaml key of an exported struct field, or "".
+func yamlKey(f reflect.StructField) string {
+	if !f.IsExported() {
+		return ""
+	}
+	name, _, _ := strings.Cut(f.Tag.Get("yaml"), ",")
+	if name == "-" {
+		return ""
+	}
+	return name
+}
+
+// commandIndex returns the position of the command with the given id, or -1.
+func commandIndex(commands []HookCommand, id string) int {
+	for i, cmd := range commands {
+		if cmd.ID == id {
+			return i
+		}
+	}
+	return -1
+}
+
+// Origin returns the file that set a top-level value, such as "timeout",
+// or OriginDefault. Only configs from LoadLayered record origins.
+func (c *Config) Origin(key string) string {
+	if pos, ok := c.origins[key]; ok {
+		return pos.File
+	}
+	return OriginDefault
+}
+
+// Origin returns the file that set a field of the command, such as "run"
+// or "env.NAME", or OriginDefault.
+func (hc HookCommand) Origin(key string) string {
+	if pos, ok := hc.origins[key]; ok {
+		return pos.File
+	}
+	return OriginDefault
+}
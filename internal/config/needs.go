@@ -0,0 +1,74 @@
+package config
+
+import (
+	"fmt"
+	"strings"
+)
+
+// validateNeeds checks the needs lists of a hook's commands: every entry
+// must be the id of another command in the same hook, and the dependencies
+// must not form a cycle. refs describes where each command was defined.
+func validateNeeds(hookName string, commands []HookCommand, refs []string) []error {
+	var errs []error
+
+	ids := make(map[string]int)
+	for i, cmd := range commands {
+		if cmd.ID != "" {
+			if _, ok := ids[cmd.ID]; !ok {
+				ids[cmd.ID] = i
+			}
+		}
+	}
+
+	for i, cmd := range commands {
+		for _, need := range cmd.Needs {
+			if _, ok := ids[need]; !ok {
+				errs = append(errs, cmd.errorAt("needs", fmt.Errorf("hook %q %s: needs unknown id %q", hookName, refs[i], need)))
+			}
+		}
+	}
+
+	// Depth-first search; a command reached again while still on the
+	// stack closes a cycle.
+	const (
+		unvisited = iota
+		visiting
+		done
+	)
+	state := make([]int, len(commands))
+	var stack []string
+
+	var visit func(i int)
+	visit = func(i int) {
+		state[i] = visiting
+		stack = append(stack, commands[i].ID)
+		for _, need := range commands[i].Needs {
+			j, ok := ids[need]
+			if !ok {
+				continue
+			}
+			switch state[j] {
+			case visiting:
+				start := 0
+				for k, id := range stack {
+					if id == need {
+						start = k
+					}
+				}
+				cycle := append(append([]string(nil), stack[start:]...), need)
+				errs = append(errs, commands[i].errorAt("needs", fmt.Errorf("hook %q: dependency cycle: %s", hookName, strings.Join(cycle, " -> "))))
+			case unvisited:
+				visit(j)
+			}
+		}
+		stack = stack[:len(stack)-1]
+		state[i] = done
+	}
+
+	for i := range commands {
+		if state[i] == unvisited {
+			visit(i)
+		}
+	}
+	return errs
+}
@@ -0,0 +1,221 @@
+package config
+
+import (
+	"fmt"
+	"reflect"
+	"sort"
+	"strings"
+
+	"gopkg.in/yaml.v3"
+)
+
+// Position is a location in a config file.
+type Position struct {
+	File   string
+	Line   int // 1-based; 0 when only the file is known
+	Column int // 1-based
+
+	source string // text of the line, for snippets
+}
+
+// String returns the position as file:line:col.
+func (p Position) String() string {
+	if p.Line == 0 {
+		return p.File
+	}
+	return fmt.Sprintf("%s:%d:%d", p.File, p.Line, p.Column)
+}
+
+// Error is a configuration error located in a config file.
+type Error struct {
+	Pos    Position // zero when the location is unknown
+	Err    error
+	Source string // the line at Pos; empty when unknown
+	Hint   string // suggested fix, such as `did you mean "timeout"?`
+}
+
+func (e *Error) Error() string {
+	if e.Pos.File == "" {
+		return e.Err.Error()
+	}
+	return e.Pos.String() + ": " + e.Err.Error()
+}
+
+func (e *Error) Unwrap() error {
+	return e.Err
+}
+
+// errorAt locates err at pos. An unknown position leaves err unchanged.
+func errorAt(pos Position, err error) error {
+	if pos.File == "" {
+		return err
+	}
+	return &Error{Pos: pos, Err: err, Source: pos.source}
+}
+
+// errorAt locates err at a top-level key, such as "timeout" or
+// "hooks.pre-commit".
+func (c *Config) errorAt(key string, err error) error {
+	return errorAt(c.origins[key], err)
+}
+
+// errorAt locates err at a field of the command, such as "timeout", or at
+// the command itself when the field is not set.
+func (hc HookCommand) errorAt(key string, err error) error {
+	pos, ok := hc.origins[key]
+	if !ok || pos.Line == 0 {
+		pos = hc.origins[""]
+	}
+	return errorAt(pos, err)
+}
+
+// nodePosition returns the position of a node in file.
+func nodePosition(node *yaml.Node, file string, lines []string) Position {
+	pos := Position{File: file, Line: node.Line, Column: node.Column}
+	if node.Line >= 1 && node.Line <= len(lines) {
+		pos.source = lines[node.Line-1]
+	}
+	return pos
+}
+
+// keyContexts names the structs whose keys checkKeys reports, for messages.
+var keyContexts = map[reflect.Type]string{
+	reflect.TypeOf(Config{}):      "config",
+	reflect.TypeOf(HookCommand{}): "command",
+	reflect.TypeOf(When{}):        "when",
+	reflect.TypeOf(Extend{}):      "extends entry",
+}
+
+// checkKeys reports every mapping key in node that has no field in t, the
+// Go type node decodes into, with a suggestion for the closest known key.
+func checkKeys(node *yaml.Node, t reflect.Type, file string, lines []string) []error {
+	for t.Kind() == reflect.Pointer {
+		t = t.Elem()
+	}
+	var errs []error
+	switch t.Kind() {
+	case reflect.Struct:
+		if node.Kind != yaml.MappingNode {
+			return nil // scalar shorthands such as extends: file.yml
+		}
+		fields := make(map[string]reflect.Type)
+		var known []string
+		for i := 0; i < t.NumField(); i++ {
+			if key := yamlKey(t.Field(i)); key != "" {
+				fields[key] = t.Field(i).Type
+				known = append(known, key)
+			}
+		}
+		for i := 0; i+1 < len(node.Content); i += 2 {
+			key, value := node.Content[i], node.Content[i+1]
+			ft, ok := fields[key.Value]
+			if !ok {
+				context := keyContexts[t]
+				if context == "" {
+					context = strings.ToLower(t.Name())
+				}
+				err := &Error{
+					Pos: nodePosition(key, file, lines),
+					Err: fmt.Errorf("unknown key %q in %s", key.Value, context),
+				}
+				err.Source = err.Pos.source
+				if s := closest(key.Value, known); s != "" {
+					err.Hint = fmt.Sprintf("did you mean %q?", s)
+				}
+				errs = append(errs, err)
+				continue
+			}
+			errs = append(errs, checkKeys(value, ft, file, lines)...)
+		}
+	case reflect.Slice:
+		if node.Kind != yaml.SequenceNode {
+			return checkKeys(node, t.Elem(), file, lines)
+		}
+		for _, item := range node.Content {
+			errs = append(errs, checkKeys(item, t.Elem(), file, lines)...)
+		}
+	case reflect.Map:
+		if node.Kind != yaml.MappingNode {
+			return nil
+		}
+		for i := 1; i < len(node.Content); i += 2 {
+			errs = append(errs, checkKeys(node.Content[i], t.Elem(), file, lines)...)
+		}
+	}
+	return errs
+}
+
+// closest returns the candidate nearest to s by edit distance, or "" when
+// none is close enough to be a likely typo.
+func closest(s string, candidates []string) string {
+	sort.Strings(candidates)
+	best, bestDist := "", len(s)
+	for _, c := range candidates {
+		if d := levenshtein(s, c); d < bestDist {
+			best, bestDist = c, d
+		}
+	}
+	if bestDist <= 3 && bestDist < len(s) {
+		return best
+	}
+	return ""
+}
+
+// recordPositions stores the position of every top-level key of the
+// config, of each hook and chain name, and of every command field.
+func (c *Config) recordPositions(root *yaml.Node, file string, lines []string) {
+	c.origins = make(map[string]Position)
+	if root.Kind == yaml.DocumentNode && len(root.Content) > 0 {
+		root = root.Content[0]
+	}
+	if root.Kind != yaml.MappingNode {
+		return
+	}
+	for i := 0; i+1 < len(root.Content); i += 2 {
+		key, value := root.Content[i], root.Content[i+1]
+		c.origins[key.Value] = nodePosition(value, file, lines)
+
+		var lists map[string][]HookCommand
+		switch key.Value {
+		case "hooks":
+			lists = c.Hooks
+		case "chains":
+			lists = c.Chains
+		default:
+			continue
+		}
+		if value.Kind != yaml.MappingNode {
+			continue
+		}
+		for j := 0; j+1 < len(value.Content); j += 2 {
+			name, items := value.Content[j], value.Content[j+1]
+			c.origins[key.Value+"."+name.Value] = nodePosition(name, file, lines)
+			commands := lists[name.Value]
+			if items.Kind != yaml.SequenceNode || len(items.Content) != len(commands) {
+				continue
+			}
+			for k, item := range items.Content {
+				commands[k].origins = commandPositions(item, file, lines)
+			}
+		}
+	}
+}
+
+// commandPositions returns the positions of a command's fields, keyed as
+// for HookCommand.Origin, with the command itself under "".
+func commandPositions(node *yaml.Node, file string, lines []string) map[string]Position {
+	positions := map[string]Position{"": nodePosition(node, file, lines)}
+	if node.Kind != yaml.MappingNode {
+		return positions
+	}
+	for i := 0; i+1 < len(node.Content); i += 2 {
+		key, value := node.Content[i], node.Content[i+1]
+		positions[key.Value] = nodePosition(value, file, lines)
+		if key.Value == "env" && value.Kind == yaml.MappingNode {
+			for j := 0; j+1 < len(value.Content); j += 2 {
+				positions["env."+value.Content[j].Value] = nodePosition(value.Content[j+1], file, lines)
+			}
+		}
+	}
+	return positions
+}
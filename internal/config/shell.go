@@ -0,0 +1,176 @@
+package config
+
+import (
+	"bufio"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+)
+
+// ScriptsDir is the directory, relative to the repository root, that holds
+// the files referenced by script.
+const ScriptsDir = ".githooks"
+
+// Shell is the interpreter that runs a command's run string. The run
+// string is appended to Argv.
+type Shell struct {
+	Name string   // sh, bash, zsh or pwsh; empty for a custom argv prefix
+	Argv []string // argv prefix, such as ["bash", "-c"]
+}
+
+// DefaultShell runs commands with sh -c.
+var DefaultShell = Shell{Name: "sh", Argv: []string{"sh", "-c"}}
+
+// shells maps the shell names accepted by the shell key to their argv
+// prefixes.
+var shells = map[string][]string{
+	"sh":   {"sh", "-c"},
+	"bash": {"bash", "-c"},
+	"zsh":  {"zsh", "-c"},
+	"pwsh": {"pwsh", "-NoProfile", "-NonInteractive", "-Command"},
+}
+
+// POSIX reports whether the shell takes positional parameters the way sh
+// does, so that hook arguments can be passed as $1, $2, ...
+func (s Shell) POSIX() bool {
+	return s.Name == "sh" || s.Name == "bash" || s.Name == "zsh"
+}
+
+// Quoting is how values substituted for placeholders such as
+// {staged_files} are quoted in a run string.
+type Quoting int
+
+const (
+	QuoteUnknown    Quoting = iota // the shell's quoting rules are not known
+	QuotePOSIX                     // '...' with ' written as '\''
+	QuotePowerShell                // '...' with ' written as ''
+)
+
+// Quoting returns how the shell quotes words. A custom argv prefix is
+// recognized by the base name of its program.
+func (s Shell) Quoting() Quoting {
+	name := s.Name
+	if name == "" && len(s.Argv) > 0 {
+		name = strings.TrimSuffix(filepath.Base(s.Argv[0]), ".exe")
+	}
+	switch name {
+	case "sh", "bash", "zsh", "dash", "ksh":
+		return QuotePOSIX
+	case "pwsh", "powershell":
+		return QuotePowerShell
+	}
+	return QuoteUnknown
+}
+
+// placeholders are the names a run string can use in braces, such as
+// {staged_files}.
+var placeholders = []string{"args", "hook", "root", "staged_files"}
+
+// usesPlaceholders reports whether run refers to a placeholder, not
+// counting shell expansions of the form ${name}.
+func usesPlaceholders(run string) bool {
+	for _, name := range placeholders {
+		token := "{" + name + "}"
+		for i := 0; ; {
+			j := strings.Index(run[i:], token)
+			if j < 0 {
+				break
+			}
+			if i+j == 0 || run[i+j-1] != '$' {
+				return true
+			}
+			i += j + len(token)
+		}
+	}
+	return false
+}
+
+// resolveShell converts the shell key to a Shell. A single value must be a
+// known shell name; a list is used as a custom argv prefix.
+func resolveShell(s StringList) (Shell, error) {
+	switch len(s) {
+	case 0:
+		return DefaultShell, nil
+	case 1:
+		argv, ok := shells[s[0]]
+		if !ok {
+			return Shell{}, fmt.Errorf("unknown shell %q: use %s, or a list giving the argv prefix such as [python3, -c]", s[0], strings.Join(shellNames(), ", "))
+		}
+		return Shell{Name: s[0], Argv: argv}, nil
+	default:
+		return Shell{Argv: append([]string(nil), s...)}, nil
+	}
+}
+
+// shellNames returns the known shell names in sorted order.
+func shellNames() []string {
+	names := make([]string, 0, len(shells))
+	for name := range shells {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// resolveScript checks that a script file exists in the scripts directory
+// under baseDir, the config file's directory. It returns the script's path
+// for display, such as .githooks/lint.sh, its absolute path and the
+// interpreter named by its shebang line.
+func resolveScript(baseDir, script string) (string, string, []string, error) {
+	if !filepath.IsLocal(filepath.FromSlash(script)) {
+		return "", "", nil, fmt.Errorf("invalid script %q: must be a relative path inside %s/", script, ScriptsDir)
+	}
+	rel := ScriptsDir + "/" + cleanRepoPath(script)
+
+	path, err := filepath.Abs(filepath.Join(baseDir, filepath.FromSlash(rel)))
+	if err != nil {
+		return "", "", nil, fmt.Errorf("invalid script %q: %w", script, err)
+	}
+	info, err := os.Stat(path)
+	if err != nil {
+		return "", "", nil, fmt.Errorf("invalid script %q: %s does not exist", script, rel)
+	}
+	if info.IsDir() {
+		return "", "", nil, fmt.Errorf("invalid script %q: %s is a directory", script, rel)
+	}
+
+	interpreter, err := readShebang(path)
+	if err != nil {
+		return "", "", nil, fmt.Errorf("invalid script %q: %w", script, err)
+	}
+	return rel, path, interpreter, nil
+}
+
+// readShebang returns the interpreter argv from a script's "#!" line, or
+// nil if it has none. "/usr/bin/env" is dropped so that the interpreter is
+// looked up in PATH, which also works on Windows.
+func readShebang(path string) ([]string, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	line, err := bufio.NewReader(f).ReadString('\n')
+	if err != nil && line == "" {
+		return nil, nil
+	}
+	rest, ok := strings.CutPrefix(line, "#!")
+	if !ok {
+		return nil, nil
+	}
+
+	argv := strings.Fields(rest)
+	if len(argv) > 0 && filepath.Base(argv[0]) == "env" {
+		argv = argv[1:]
+		if len(argv) > 0 && argv[0] == "-S" {
+			argv = argv[1:]
+		}
+	}
+	if len(argv) == 0 {
+		return nil, fmt.Errorf("empty #! line")
+	}
+	return argv, nil
+}
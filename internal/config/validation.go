@@ -2,6 +2,7 @@ package config
 
 import (
 	"fmt"
+	"regexp"
 	"strings"
 )
 
@@ -28,6 +29,25 @@ var StandardHooks = []string{
 	"sendemail-validate",
 }
 
+// StdinHooks lists the hooks that Git feeds data on standard input, such as
+// the ref updates for pre-push or the rewritten SHAs for post-rewrite.
+var StdinHooks = []string{
+	"pre-push",
+	"pre-receive",
+	"post-receive",
+	"post-rewrite",
+}
+
+// ReceivesStdin reports whether Git passes data on stdin to the given hook.
+func ReceivesStdin(name string) bool {
+	for _, h := range StdinHooks {
+		if h == name {
+			return true
+		}
+	}
+	return false
+}
+
 // ValidateHookName checks if the given name is a recognized Git hook name.
 func ValidateHookName(name string) error {
 	for _, h := range StandardHooks {
@@ -39,6 +59,18 @@ func ValidateHookName(name string) error {
 		name, strings.Join(StandardHooks, ", "))
 }
 
+// commandIDPattern restricts ids to characters that are safe in GHM_SKIP
+// lists and on the command line.
+var commandIDPattern = regexp.MustCompile(`^[A-Za-z0-9][A-Za-z0-9_.-]*$`)
+
+// ValidateCommandID checks that a command id is well formed.
+func ValidateCommandID(id string) error {
+	if !commandIDPattern.MatchString(id) {
+		return fmt.Errorf("invalid id %q: must start with a letter or digit and contain only letters, digits, '.', '_' or '-'", id)
+	}
+	return nil
+}
+
 // SuggestHookName returns the closest matching hook name if the Levenshtein
 // distance is within a reasonable threshold, or an empty string if no close match.
 func SuggestHookName(name string) string {
@@ -96,4 +128,3 @@ func levenshtein(a, b string) int {
 
 	return prev[lb]
 }
-
@@ -3,7 +3,6 @@ package git
 import (
 	"fmt"
 	"os/exec"
-	"path/filepath"
 	"strings"
 )
 
@@ -18,25 +17,130 @@ func GetRepoRoot() (string, error) {
 	return strings.TrimSpace(string(output)), nil
 }
 
-// GetHooksDir returns the path to the Git hooks directory,
-// respecting core.hooksPath if configured.
-func GetHooksDir() (string, error) {
-	cmd := exec.Command("git", "rev-parse", "--git-path", "hooks/")
+// GetCurrentBranch returns the short name of the checked-out branch, or an
+// empty string when HEAD is detached.
+func GetCurrentBranch() (string, error) {
+	cmd := exec.Command("git", "symbolic-ref", "--short", "-q", "HEAD")
 	output, err := cmd.Output()
 	if err != nil {
-		return "", fmt.Errorf("failed to determine hooks directory: %w", err)
+		if exitErr, ok := err.(*exec.ExitError); ok && exitErr.ExitCode() == 1 {
+			return "", nil // detached HEAD
+		}
+		return "", fmt.Errorf("failed to determine current branch: %w", err)
 	}
+	return strings.TrimSpace(string(output)), nil
+}
 
-	hooksDir := strings.TrimSpace(string(output))
+// GetStagedFiles returns the paths of files staged for commit, relative to
+// the repository root. Deleted files are not included.
+func GetStagedFiles() ([]string, error) {
+	cmd := exec.Command("git", "diff", "--cached", "--name-only", "--diff-filter=ACMR", "-z")
+	output, err := cmd.Output()
+	if err != nil {
+		return nil, fmt.Errorf("failed to list staged files: %w", err)
+	}
+	return splitNul(output), nil
+}
 
-	// Ensure absolute path
-	if !filepath.IsAbs(hooksDir) {
-		repoRoot, err := GetRepoRoot()
-		if err != nil {
-			return "", err
+// GetAllFiles returns the paths of all tracked files, relative to the
+// repository root.
+func GetAllFiles() ([]string, error) {
+	cmd := exec.Command("git", "ls-files", "-z", "--full-name", ":/")
+	output, err := cmd.Output()
+	if err != nil {
+		return nil, fmt.Errorf("failed to list tracked files: %w", err)
+	}
+	return splitNul(output), nil
+}
+
+// GetChangedFiles returns the paths of files added, copied, modified or
+// renamed on toRef since it diverged from fromRef (the three-dot range
+// fromRef...toRef), relative to the repository root.
+func GetChangedFiles(fromRef, toRef string) ([]string, error) {
+	cmd := exec.Command("git", "diff", "--name-only", "--diff-filter=ACMR", "-z", fromRef+"..."+toRef, "--")
+	output, err := cmd.Output()
+	if err != nil {
+		if exitErr, ok := err.(*exec.ExitError); ok && len(exitErr.Stderr) > 0 {
+			return nil, fmt.Errorf("failed to diff %s...%s: %s", fromRef, toRef, strings.TrimSpace(string(exitErr.Stderr)))
 		}
-		hooksDir = filepath.Join(repoRoot, hooksDir)
+		return nil, fmt.Errorf("failed to diff %s...%s: %w", fromRef, toRef, err)
+	}
+	return splitNul(output), nil
+}
+
+// GetPushedFiles returns the paths of files added, copied, modified or
+// renamed by the commits a push of localSha sends, relative to repoRoot.
+// When remoteSha is unknown locally or all zeros, as for a new branch, the
+// commits are those not yet on any remote-tracking branch.
+func GetPushedFiles(repoRoot, localSha, remoteSha string) ([]string, error) {
+	var output []byte
+	var err error
+	if isZeroSha(remoteSha) || !commitExists(repoRoot, remoteSha) {
+		output, err = runGit(repoRoot, "log", "--name-only", "--diff-filter=ACMR", "--format=", "-z", localSha, "--not", "--remotes")
+	} else {
+		output, err = runGit(repoRoot, "diff", "--name-only", "--diff-filter=ACMR", "-z", remoteSha+"..."+localSha, "--")
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to list pushed files: %w", err)
 	}
 
-	return filepath.Clean(hooksDir), nil
+	// log separates commits with newlines as well
+	seen := make(map[string]bool)
+	var files []string
+	for _, entry := range splitNul(output) {
+		entry = strings.Trim(entry, "\n")
+		if entry != "" && !seen[entry] {
+			seen[entry] = true
+			files = append(files, entry)
+		}
+	}
+	return files, nil
+}
+
+// isZeroSha reports whether sha is Git's all-zeros object id, which stands
+// for a ref that does not exist.
+func isZeroSha(sha string) bool {
+	return strings.Trim(sha, "0") == ""
+}
+
+// commitExists reports whether sha names a commit in the repository.
+func commitExists(repoRoot, sha string) bool {
+	_, err := runGit(repoRoot, "cat-file", "-e", sha+"^{commit}")
+	return err == nil
+}
+
+// splitNul splits NUL-terminated git output into its entries.
+func splitNul(output []byte) []string {
+	var entries []string
+	for _, entry := range strings.Split(string(output), "\x00") {
+		if entry != "" {
+			entries = append(entries, entry)
+		}
+	}
+	return entries
+}
+
+// GetUnstagedFiles returns the paths of tracked files whose working-tree
+// content differs from the index, relative to repoRoot.
+func GetUnstagedFiles(repoRoot string) ([]string, error) {
+	output, err := runGit(repoRoot, "diff", "--name-only", "-z", "--no-ext-diff", "--ignore-submodules")
+	if err != nil {
+		return nil, fmt.Errorf("failed to list unstaged changes: %w", err)
+	}
+	return splitNul(output), nil
+}
+
+// AddFiles stages the given paths, relative to repoRoot. Paths are passed
+// on stdin so that long lists do not exceed the command line limit.
+func AddFiles(repoRoot string, files []string) error {
+	if len(files) == 0 {
+		return nil
+	}
+	cmd := exec.Command("git", "add", "--pathspec-from-file=-", "--pathspec-file-nul")
+	cmd.Dir = repoRoot
+	cmd.Stdin = strings.NewReader(strings.Join(files, "\x00") + "\x00")
+	if output, err := cmd.CombinedOutput(); err != nil {
+		return fmt.Errorf("git add failed: %s", strings.TrimSpace(string(output)))
+	}
+	return nil
 }
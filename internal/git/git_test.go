@@ -2,6 +2,7 @@ package git
 
 import (
 	"os"
+	"os/exec"
 	"path/filepath"
 	"testing"
 )
@@ -18,19 +19,195 @@ func TestGetRepoRoot(t *testing.T) {
 	}
 }
 
-func TestGetHooksDir(t *testing.T) {
-	hooksDir, err := GetHooksDir()
+func TestGitPath_Hooks(t *testing.T) {
+	dir := initTestRepo(t)
+	sub := filepath.Join(dir, "a", "b")
+	if err := os.MkdirAll(sub, 0755); err != nil {
+		t.Fatal(err)
+	}
+	origDir, _ := os.Getwd()
+	defer os.Chdir(origDir)
+	if err := os.Chdir(sub); err != nil {
+		t.Fatal(err)
+	}
+
+	hooksDir, err := GitPath(dir, "hooks")
+	if err != nil {
+		t.Fatalf("GitPath() error = %v", err)
+	}
+	if want := filepath.Join(dir, ".git", "hooks"); hooksDir != want {
+		t.Errorf("GitPath() = %q, want %q", hooksDir, want)
+	}
+}
+
+func TestSplitNul(t *testing.T) {
+	got := splitNul([]byte("a.go\x00dir/with space.txt\x00"))
+	if len(got) != 2 || got[0] != "a.go" || got[1] != "dir/with space.txt" {
+		t.Errorf("splitNul() = %q, want [a.go dir/with space.txt]", got)
+	}
+	if got := splitNul(nil); len(got) != 0 {
+		t.Errorf("splitNul(nil) = %q, want empty", got)
+	}
+}
+
+func TestStashUnstaged_RoundTrip(t *testing.T) {
+	dir := initTestRepo(t)
+	writeFile(t, dir, "f.txt", "one\nstaged\n")
+	gitCmd(t, dir, "add", "f.txt")
+	writeFile(t, dir, "f.txt", "one\nstaged\nunstaged\n")
+	writeFile(t, dir, "new/untracked.txt", "u\n")
+
+	stash, err := StashUnstaged(dir)
+	if err != nil {
+		t.Fatalf("StashUnstaged() error = %v", err)
+	}
+
+	if got := readFile(t, dir, "f.txt"); got != "one\nstaged\n" {
+		t.Errorf("f.txt while stashed = %q, want the staged content", got)
+	}
+	if _, err := os.Stat(filepath.Join(dir, "new/untracked.txt")); err == nil {
+		t.Error("untracked file should be moved aside while stashed")
+	}
+
+	if err := stash.Restore(); err != nil {
+		t.Fatalf("Restore() error = %v", err)
+	}
+	if stash.Conflicted {
+		t.Error("Conflicted = true, want false")
+	}
+	if got := readFile(t, dir, "f.txt"); got != "one\nstaged\nunstaged\n" {
+		t.Errorf("f.txt after restore = %q, want the unstaged content", got)
+	}
+	if got := readFile(t, dir, "new/untracked.txt"); got != "u\n" {
+		t.Errorf("untracked.txt after restore = %q, want %q", got, "u\n")
+	}
+}
+
+func TestStashUnstaged_ConflictDiscardsHookChanges(t *testing.T) {
+	dir := initTestRepo(t)
+	writeFile(t, dir, "f.txt", "one\nstaged\n")
+	gitCmd(t, dir, "add", "f.txt")
+	writeFile(t, dir, "f.txt", "one\nstaged\nunstaged\n")
+
+	stash, err := StashUnstaged(dir)
 	if err != nil {
-		t.Fatalf("GetHooksDir() error = %v", err)
+		t.Fatalf("StashUnstaged() error = %v", err)
 	}
+	writeFile(t, dir, "f.txt", "one\nstaged\nformatted\n")
 
-	// Should be an absolute path
-	if !filepath.IsAbs(hooksDir) {
-		t.Errorf("GetHooksDir() = %q, want absolute path", hooksDir)
+	if err := stash.Restore(); err != nil {
+		t.Fatalf("Restore() error = %v", err)
+	}
+	if !stash.Conflicted {
+		t.Error("Conflicted = false, want true")
+	}
+	if got := readFile(t, dir, "f.txt"); got != "one\nstaged\nunstaged\n" {
+		t.Errorf("f.txt after restore = %q, want the unstaged content", got)
+	}
+}
+
+func TestStashUnstaged_NothingToStash(t *testing.T) {
+	dir := initTestRepo(t)
+
+	stash, err := StashUnstaged(dir)
+	if err != nil {
+		t.Fatalf("StashUnstaged() error = %v", err)
+	}
+	if err := stash.Restore(); err != nil {
+		t.Fatalf("Restore() error = %v", err)
+	}
+	if _, err := os.Stat(filepath.Join(dir, ".git", "ghm")); err == nil {
+		t.Error("no stash directory should be created when there is nothing to stash")
+	}
+}
+
+// initTestRepo creates a repository with one committed file, f.txt.
+func initTestRepo(t *testing.T) string {
+	t.Helper()
+	dir := t.TempDir()
+	gitCmd(t, dir, "init", "-q")
+	gitCmd(t, dir, "config", "user.email", "test@example.com")
+	gitCmd(t, dir, "config", "user.name", "test")
+	writeFile(t, dir, "f.txt", "one\n")
+	gitCmd(t, dir, "add", "f.txt")
+	gitCmd(t, dir, "commit", "-q", "-m", "init")
+	return dir
+}
+
+func gitCmd(t *testing.T, dir string, args ...string) {
+	t.Helper()
+	cmd := exec.Command("git", args...)
+	cmd.Dir = dir
+	if output, err := cmd.CombinedOutput(); err != nil {
+		t.Fatalf("git %v: %v\n%s", args, err, output)
+	}
+}
+
+func writeFile(t *testing.T, dir, name, content string) {
+	t.Helper()
+	path := filepath.Join(dir, name)
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(path, []byte(content), 0644); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func readFile(t *testing.T, dir, name string) string {
+	t.Helper()
+	data, err := os.ReadFile(filepath.Join(dir, name))
+	if err != nil {
+		t.Fatal(err)
+	}
+	return string(data)
+}
+
+func TestGetAllFiles(t *testing.T) {
+	files, err := GetAllFiles()
+	if err != nil {
+		t.Fatalf("GetAllFiles() error = %v", err)
+	}
+
+	found := false
+	for _, f := range files {
+		if f == "go.mod" {
+			found = true
+		}
+	}
+	if !found {
+		t.Errorf("GetAllFiles() = %v, want it to include go.mod", files)
+	}
+}
+
+func TestFetchRefAndShowFile(t *testing.T) {
+	repo := initTestRepo(t)
+	gitCmd(t, repo, "tag", "v1")
+	writeFile(t, repo, "f.txt", "two\n")
+	gitCmd(t, repo, "commit", "-q", "-am", "second")
+
+	want, err := ResolveCommit(repo, "v1")
+	if err != nil {
+		t.Fatalf("ResolveCommit() error = %v", err)
+	}
+
+	cacheRepo := filepath.Join(t.TempDir(), "cache")
+	commit, err := FetchRef(cacheRepo, "file://"+repo, "v1")
+	if err != nil {
+		t.Fatalf("FetchRef() error = %v", err)
+	}
+	if commit != want {
+		t.Errorf("FetchRef() = %s, want %s", commit, want)
+	}
+	content, err := ShowFile(cacheRepo, commit, "f.txt")
+	if err != nil {
+		t.Fatalf("ShowFile() error = %v", err)
+	}
+	if string(content) != "one\n" {
+		t.Errorf("ShowFile() = %q, want %q", content, "one\n")
 	}
 
-	// Should end with "hooks"
-	if filepath.Base(hooksDir) != "hooks" {
-		t.Errorf("GetHooksDir() = %q, want path ending in 'hooks'", hooksDir)
+	if _, err := ShowFile(repo, commit, "missing.txt"); err == nil {
+		t.Error("expected error for missing file")
 	}
 }
@@ -0,0 +1,58 @@
+package git
+
+import (
+	"fmt"
+	"os"
+	"strings"
+)
+
+// GitPath returns the absolute path of a file inside the Git directory of
+// the repository at repoRoot, such as "githookd/cache.json".
+func GitPath(repoRoot, name string) (string, error) {
+	return gitPath(repoRoot, name)
+}
+
+// ResolveCommit returns the commit that ref names in the repository at
+// repo.
+func ResolveCommit(repo, ref string) (string, error) {
+	output, err := runGit(repo, "rev-parse", "--verify", "--end-of-options", ref+"^{commit}")
+	if err != nil {
+		return "", fmt.Errorf("failed to resolve %s in %s: %w", ref, repo, err)
+	}
+	return strings.TrimSpace(string(output)), nil
+}
+
+// ShowFile returns the content of path at commit in the repository at repo.
+func ShowFile(repo, commit, path string) ([]byte, error) {
+	output, err := runGit(repo, "show", commit+":"+path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read %s at %s: %w", path, shortCommit(commit), err)
+	}
+	return output, nil
+}
+
+// FetchRef fetches ref from the repository at url into the bare repository
+// at cacheRepo, creating it if needed, and returns the fetched commit. Only
+// the commit itself is fetched, without history.
+func FetchRef(cacheRepo, url, ref string) (string, error) {
+	if _, err := os.Stat(cacheRepo); os.IsNotExist(err) {
+		if err := os.MkdirAll(cacheRepo, 0755); err != nil {
+			return "", err
+		}
+		if _, err := runGit(cacheRepo, "init", "--bare", "-q"); err != nil {
+			return "", err
+		}
+	}
+	if _, err := runGit(cacheRepo, "fetch", "-q", "--depth=1", "--no-tags", url, ref); err != nil {
+		return "", fmt.Errorf("failed to fetch %s from %s: %w", ref, url, err)
+	}
+	return ResolveCommit(cacheRepo, "FETCH_HEAD")
+}
+
+// shortCommit abbreviates a commit id for messages.
+func shortCommit(commit string) string {
+	if len(commit) > 12 {
+		return commit[:12]
+	}
+	return commit
+}
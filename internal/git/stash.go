@@ -0,0 +1,266 @@
+package git
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"os/exec"
+	"os/signal"
+	"path/filepath"
+	"strings"
+	"syscall"
+	"time"
+)
+
+// Stash holds the unstaged and untracked changes that were set aside so
+// that the working tree matches the index while pre-commit commands run.
+type Stash struct {
+	// Conflicted is set by Restore when the stashed changes could not be
+	// applied on top of the files the hook commands modified, and those
+	// files were put back as they were before the hook in the working tree.
+	// The hook's modifications remain in the index if they were staged.
+	Conflicted bool
+
+	// Interrupted is set by Restore when SIGINT or SIGTERM arrived while
+	// the changes were stashed.
+	Interrupted bool
+
+	repoRoot  string
+	patchPath string   // unstaged changes; empty if there were none
+	filesDir  string   // working-tree copies of the files in the patch
+	modified  []string // files in the patch, relative to repoRoot
+	backupDir string   // untracked files; empty if there were none
+	untracked []string // paths relative to repoRoot
+	signals   chan os.Signal
+}
+
+// StashUnstaged saves the unstaged changes as a patch, along with a copy of
+// each file they touch, and moves untracked files aside, leaving the
+// working tree identical to the index. All of it is kept under the Git
+// directory until Restore puts it back.
+//
+// While the changes are stashed, SIGINT and SIGTERM do not terminate the
+// process so that Restore always gets a chance to run.
+func StashUnstaged(repoRoot string) (*Stash, error) {
+	s := &Stash{repoRoot: repoRoot}
+
+	// Resetting the working tree during a merge would lose the conflict
+	// state, so leave it untouched.
+	if mergeHead, err := gitPath(repoRoot, "MERGE_HEAD"); err == nil {
+		if _, err := os.Stat(mergeHead); err == nil {
+			return s, nil
+		}
+	}
+
+	patch, err := runGit(repoRoot, "diff", "--binary", "--no-color", "--no-ext-diff", "--ignore-submodules")
+	if err != nil {
+		return nil, fmt.Errorf("failed to diff unstaged changes: %w", err)
+	}
+	untrackedOut, err := runGit(repoRoot, "ls-files", "--others", "--exclude-standard", "-z")
+	if err != nil {
+		return nil, fmt.Errorf("failed to list untracked files: %w", err)
+	}
+	untracked := splitNul(untrackedOut)
+	var modified []string
+	if len(patch) > 0 {
+		if modified, err = GetUnstagedFiles(repoRoot); err != nil {
+			return nil, err
+		}
+	}
+
+	if len(patch) == 0 && len(untracked) == 0 {
+		return s, nil
+	}
+
+	stashDir, err := gitPath(repoRoot, "ghm")
+	if err != nil {
+		return nil, err
+	}
+	if err := os.MkdirAll(stashDir, 0755); err != nil {
+		return nil, fmt.Errorf("failed to create stash directory: %w", err)
+	}
+	stamp := time.Now().Format("20060102150405.000000000")
+
+	s.signals = make(chan os.Signal, 1)
+	signal.Notify(s.signals, os.Interrupt, syscall.SIGTERM)
+
+	if len(patch) > 0 {
+		s.patchPath = filepath.Join(stashDir, "unstaged-"+stamp+".patch")
+		if err := os.WriteFile(s.patchPath, patch, 0644); err != nil {
+			s.release()
+			return nil, fmt.Errorf("failed to save unstaged changes: %w", err)
+		}
+		s.filesDir = filepath.Join(stashDir, "unstaged-"+stamp)
+		for _, name := range modified {
+			err := copyFile(filepath.Join(repoRoot, name), filepath.Join(s.filesDir, name))
+			if err != nil && !errors.Is(err, fs.ErrNotExist) {
+				s.release()
+				return nil, fmt.Errorf("failed to save unstaged changes to %s: %w", name, err)
+			}
+		}
+		s.modified = modified
+		if _, err := runGit(repoRoot, "checkout", "--", "."); err != nil {
+			s.release()
+			return nil, fmt.Errorf("failed to reset unstaged changes (saved in %s): %w", s.patchPath, err)
+		}
+	}
+
+	if len(untracked) > 0 {
+		s.backupDir = filepath.Join(stashDir, "untracked-"+stamp)
+		for _, name := range untracked {
+			if err := moveFile(filepath.Join(repoRoot, name), filepath.Join(s.backupDir, name)); err != nil {
+				restoreErr := s.Restore()
+				if restoreErr != nil {
+					return nil, fmt.Errorf("failed to move untracked file %s aside: %w (restoring: %v)", name, err, restoreErr)
+				}
+				return nil, fmt.Errorf("failed to move untracked file %s aside: %w", name, err)
+			}
+			s.untracked = append(s.untracked, name)
+		}
+	}
+
+	return s, nil
+}
+
+// Restore re-applies the stashed unstaged changes and moves untracked files
+// back into place. If the patch no longer applies because hook commands
+// modified the same lines, the saved copies of the files in the patch are
+// written back instead, so the working tree holds exactly what it did
+// before the hook; Conflicted reports when this happened. On failure the
+// saved patch and files are left in place and named in the error.
+func (s *Stash) Restore() error {
+	defer s.release()
+
+	var errs []string
+
+	if s.patchPath != "" {
+		if _, err := runGit(s.repoRoot, "apply", "--whitespace=nowarn", s.patchPath); err != nil {
+			s.Conflicted = true
+			for _, name := range s.modified {
+				if err := s.restoreFile(name); err != nil {
+					errs = append(errs, fmt.Sprintf("failed to restore unstaged changes to %s: %v", name, err))
+				}
+			}
+		}
+		if len(errs) > 0 {
+			errs[len(errs)-1] += fmt.Sprintf(" (saved in %s and %s)", s.patchPath, s.filesDir)
+		} else {
+			os.Remove(s.patchPath)
+			os.RemoveAll(s.filesDir)
+		}
+	}
+
+	var kept []string
+	for _, name := range s.untracked {
+		dst := filepath.Join(s.repoRoot, name)
+		if _, err := os.Lstat(dst); err == nil {
+			kept = append(kept, name)
+			continue
+		}
+		if err := moveFile(filepath.Join(s.backupDir, name), dst); err != nil {
+			kept = append(kept, name)
+		}
+	}
+	if len(kept) > 0 {
+		errs = append(errs, fmt.Sprintf("could not restore untracked files %s (kept in %s)", strings.Join(kept, ", "), s.backupDir))
+	} else if s.backupDir != "" {
+		os.RemoveAll(s.backupDir)
+	}
+
+	if len(errs) > 0 {
+		return fmt.Errorf("%s", strings.Join(errs, "; "))
+	}
+	return nil
+}
+
+// release stops holding off signals and records whether any arrived.
+func (s *Stash) release() {
+	if s.signals == nil {
+		return
+	}
+	signal.Stop(s.signals)
+	select {
+	case <-s.signals:
+		s.Interrupted = true
+	default:
+	}
+	s.signals = nil
+}
+
+// restoreFile writes the saved copy of a file in the patch back to the
+// working tree, or removes the file if the unstaged change deleted it.
+func (s *Stash) restoreFile(name string) error {
+	dst := filepath.Join(s.repoRoot, name)
+	if err := os.Remove(dst); err != nil && !errors.Is(err, fs.ErrNotExist) {
+		return err
+	}
+	err := copyFile(filepath.Join(s.filesDir, name), dst)
+	if errors.Is(err, fs.ErrNotExist) {
+		return nil
+	}
+	return err
+}
+
+// copyFile copies src to dst with its permissions, creating dst's parent
+// directories. Symbolic links are copied as links.
+func copyFile(src, dst string) error {
+	info, err := os.Lstat(src)
+	if err != nil {
+		return err
+	}
+	if err := os.MkdirAll(filepath.Dir(dst), 0755); err != nil {
+		return err
+	}
+	if info.Mode()&os.ModeSymlink != 0 {
+		target, err := os.Readlink(src)
+		if err != nil {
+			return err
+		}
+		return os.Symlink(target, dst)
+	}
+	data, err := os.ReadFile(src)
+	if err != nil {
+		return err
+	}
+	return os.WriteFile(dst, data, info.Mode().Perm())
+}
+
+// moveFile renames src to dst, creating dst's parent directories.
+func moveFile(src, dst string) error {
+	if err := os.MkdirAll(filepath.Dir(dst), 0755); err != nil {
+		return err
+	}
+	return os.Rename(src, dst)
+}
+
+// gitPath returns the absolute path of a file inside the Git directory.
+func gitPath(repoRoot, name string) (string, error) {
+	output, err := runGit(repoRoot, "rev-parse", "--git-path", name)
+	if err != nil {
+		return "", fmt.Errorf("failed to resolve git path %s: %w", name, err)
+	}
+	p := strings.TrimSpace(string(output))
+	if !filepath.IsAbs(p) {
+		p = filepath.Join(repoRoot, p)
+	}
+	return filepath.Clean(p), nil
+}
+
+// runGit runs a git command in dir and returns its stdout. The error
+// includes git's stderr.
+func runGit(dir string, args ...string) ([]byte, error) {
+	cmd := exec.Command("git", args...)
+	cmd.Dir = dir
+	var stderr bytes.Buffer
+	cmd.Stderr = &stderr
+	output, err := cmd.Output()
+	if err != nil {
+		if msg := strings.TrimSpace(stderr.String()); msg != "" {
+			return nil, fmt.Errorf("git %s: %s", args[0], msg)
+		}
+		return nil, fmt.Errorf("git %s: %w", args[0], err)
+	}
+	return output, nil
+}
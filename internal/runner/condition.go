@@ -0,0 +1,217 @@
+package runner
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"os"
+	"os/exec"
+	"path"
+	"runtime"
+	"slices"
+	"strings"
+
+	"githookd/internal/config"
+	"githookd/internal/git"
+)
+
+// checkCondition evaluates a command's when and except blocks. It reports
+// whether the command should run, and why: the when conditions that held,
+// or the first one that did not, or the except conditions that held.
+func checkCondition(hookName string, command config.ResolvedHookCommand, opts Options) (bool, string) {
+	if command.When == nil && command.Except == nil {
+		return true, "no conditions"
+	}
+
+	var why []string
+	if command.When != nil {
+		ok, reason := matchCondition(hookName, command.When, command, opts)
+		if !ok {
+			return false, "when: " + reason
+		}
+		why = append(why, "when: "+reason)
+	}
+	if command.Except != nil {
+		ok, reason := matchCondition(hookName, command.Except, command, opts)
+		if ok {
+			return false, "except: " + reason
+		}
+		why = append(why, "except: "+reason)
+	}
+	return true, strings.Join(why, "; ")
+}
+
+// matchCondition reports whether every condition in cond holds, and why:
+// the conditions that held, or the first one that did not.
+func matchCondition(hookName string, cond *config.Condition, command config.ResolvedHookCommand, opts Options) (bool, string) {
+	var met []string
+
+	if len(cond.Branches) > 0 {
+		// A pre-push hook matches the branches being pushed, not the one
+		// that happens to be checked out
+		var branches []string
+		if hookName == "pre-push" {
+			branches = pushedBranches(opts.Stdin)
+			if len(branches) == 0 {
+				return false, "no branch is being pushed"
+			}
+		} else if opts.Branch != "" {
+			branches = []string{opts.Branch}
+		}
+		if len(branches) == 0 {
+			return false, "not on a branch"
+		}
+		matched := ""
+		for _, b := range branches {
+			if matchBranch(cond.Branches, b) {
+				matched = b
+				break
+			}
+		}
+		if matched == "" {
+			return false, fmt.Sprintf("branch %s does not match %s", strings.Join(branches, ", "), strings.Join(cond.Branches, ", "))
+		}
+		met = append(met, fmt.Sprintf("branch %s matches", matched))
+	}
+
+	for _, env := range cond.Env {
+		value := os.Getenv(env.Name)
+		switch {
+		case env.HasValue && value != env.Value:
+			return false, fmt.Sprintf("%s is not %q", env.Name, env.Value)
+		case !env.HasValue && value == "":
+			return false, fmt.Sprintf("%s is not set", env.Name)
+		}
+		met = append(met, fmt.Sprintf("env %s", env))
+	}
+
+	if cond.ChangedPaths != nil {
+		files := opts.Files
+		if hookName == "pre-push" && opts.Files == nil {
+			files = opts.pushed
+		}
+		matched := cond.ChangedPaths.Filter(files)
+		if len(matched) == 0 {
+			return false, "no changed paths match"
+		}
+		met = append(met, fmt.Sprintf("%d changed paths match", len(matched)))
+	}
+
+	if len(cond.OS) > 0 {
+		if !slices.Contains(cond.OS, runtime.GOOS) {
+			return false, fmt.Sprintf("os %s is not %s", runtime.GOOS, strings.Join(cond.OS, ", "))
+		}
+		met = append(met, "os "+runtime.GOOS)
+	}
+
+	if cond.Shell != "" {
+		var ctx context.Context
+		var cancel context.CancelFunc
+		if command.Timeout > 0 {
+			ctx, cancel = context.WithTimeout(context.Background(), command.Timeout)
+		} else {
+			ctx, cancel = context.WithCancel(context.Background())
+		}
+		defer cancel()
+
+		cmd := exec.CommandContext(ctx, "sh", "-c", cond.Shell)
+		cmd.Dir = opts.RepoRoot
+		cmd.Env = append(os.Environ(),
+			"GHM_HOOK_NAME="+hookName,
+			"GHM_ROOT="+opts.RepoRoot,
+		)
+		if err := cmd.Run(); err != nil {
+			if exitErr, ok := err.(*exec.ExitError); ok {
+				return false, fmt.Sprintf("shell condition exited with status %d", exitErr.ExitCode())
+			}
+			return false, fmt.Sprintf("shell condition failed: %v", err)
+		}
+		met = append(met, "shell condition passed")
+	}
+
+	return true, strings.Join(met, "; ")
+}
+
+// matchBranch reports whether a branch name matches any of the patterns.
+// Patterns containing a slash may use "**" to span several components.
+func matchBranch(patterns []string, branch string) bool {
+	for _, p := range patterns {
+		if strings.Contains(p, "/") {
+			if config.MatchGlob(p, branch) {
+				return true
+			}
+			continue
+		}
+		if ok, _ := path.Match(p, branch); ok {
+			return true
+		}
+	}
+	return false
+}
+
+// pushUpdate is one ref a pre-push hook is updating.
+type pushUpdate struct {
+	localRef, localSha, remoteRef, remoteSha string
+}
+
+// pushUpdates parses the "<local ref> <local sha> <remote ref> <remote sha>"
+// lines Git writes to a pre-push hook's stdin.
+func pushUpdates(stdin []byte) []pushUpdate {
+	var updates []pushUpdate
+	for _, line := range strings.Split(string(stdin), "\n") {
+		fields := strings.Fields(line)
+		if len(fields) != 4 {
+			continue
+		}
+		updates = append(updates, pushUpdate{fields[0], fields[1], fields[2], fields[3]})
+	}
+	return updates
+}
+
+// pushedBranches returns the remote branches a pre-push hook is updating.
+func pushedBranches(stdin []byte) []string {
+	var branches []string
+	for _, u := range pushUpdates(stdin) {
+		if branch, ok := strings.CutPrefix(u.remoteRef, "refs/heads/"); ok {
+			branches = append(branches, branch)
+		}
+	}
+	return branches
+}
+
+// pushedFiles returns the files changed by the commits a pre-push hook is
+// sending. Deleted refs send no commits.
+func pushedFiles(repoRoot string, stdin []byte) []string {
+	seen := make(map[string]bool)
+	var files []string
+	for _, u := range pushUpdates(stdin) {
+		if strings.Trim(u.localSha, "0") == "" {
+			continue
+		}
+		changed, err := git.GetPushedFiles(repoRoot, u.localSha, u.remoteSha)
+		if err != nil {
+			slog.Warn("Cannot list pushed files", "ref", u.localRef, "error", err)
+			continue
+		}
+		for _, f := range changed {
+			if !seen[f] {
+				seen[f] = true
+				files = append(files, f)
+			}
+		}
+	}
+	return files
+}
+
+// usesChangedPaths reports whether any command has a changed_paths
+// condition.
+func usesChangedPaths(commands []config.ResolvedHookCommand) bool {
+	for _, command := range commands {
+		for _, cond := range []*config.Condition{command.When, command.Except} {
+			if cond != nil && cond.ChangedPaths != nil {
+				return true
+			}
+		}
+	}
+	return false
+}
@@ -0,0 +1,169 @@
+package runner
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"githookd/internal/config"
+)
+
+// commandDir returns the absolute working directory of a command. It checks
+// that the directory exists and, once symlinks are resolved, is still
+// inside the repository.
+func commandDir(repoRoot, dir string) (string, error) {
+	if dir == "" {
+		return repoRoot, nil
+	}
+
+	abs := filepath.Join(repoRoot, filepath.FromSlash(dir))
+	resolved, err := filepath.EvalSymlinks(abs)
+	if err != nil {
+		return "", fmt.Errorf("invalid dir %q: %w", dir, err)
+	}
+	root, err := filepath.EvalSymlinks(repoRoot)
+	if err != nil {
+		return "", err
+	}
+	rel, err := filepath.Rel(root, resolved)
+	if err != nil || !filepath.IsLocal(rel) {
+		return "", fmt.Errorf("invalid dir %q: resolves to %s, outside the repository", dir, resolved)
+	}
+	if info, err := os.Stat(resolved); err != nil || !info.IsDir() {
+		return "", fmt.Errorf("invalid dir %q: not a directory", dir)
+	}
+	return abs, nil
+}
+
+// maxEnvFilesLen is the longest file list passed in GHM_STAGED_FILES.
+// Longer lists are written to a temporary file named by
+// GHM_STAGED_FILES_FILE instead. A variable so tests can lower it.
+var maxEnvFilesLen = 32 * 1024
+
+// fileListTooLong reports whether files must be passed in a temporary file
+// rather than in GHM_STAGED_FILES.
+func fileListTooLong(files []string) bool {
+	n := 0
+	for _, f := range files {
+		n += len(f) + 1
+	}
+	return n > maxEnvFilesLen
+}
+
+// writeFileList writes files, one per line, to a new temporary file and
+// returns its path. The caller removes the file.
+func writeFileList(files []string) (string, error) {
+	f, err := os.CreateTemp("", "ghm-files-*.txt")
+	if err != nil {
+		return "", err
+	}
+	_, err = f.WriteString(strings.Join(files, "\n") + "\n")
+	if cerr := f.Close(); err == nil {
+		err = cerr
+	}
+	if err != nil {
+		os.Remove(f.Name())
+		return "", err
+	}
+	return f.Name(), nil
+}
+
+// builtinEnv returns the GHM_* variables describing a command's run. When
+// the command's file list is too long for the environment, filesPath names
+// the file holding it.
+func builtinEnv(hookName string, j job, opts Options, filesPath string) []string {
+	quoted := make([]string, len(opts.HookArgs))
+	for i, arg := range opts.HookArgs {
+		quoted[i] = shellQuote(arg)
+	}
+
+	env := []string{
+		"GHM_HOOK_NAME=" + hookName,
+		"GHM_ROOT=" + opts.RepoRoot,
+		"GHM_HOOK_ARGS=" + strings.Join(quoted, " "),
+		"GHM_COMMAND_ID=" + j.command.ID,
+		"GHM_BRANCH=" + opts.Branch,
+	}
+	if fileListTooLong(j.files) {
+		env = append(env, "GHM_STAGED_FILES_FILE="+filesPath)
+	} else {
+		env = append(env, "GHM_STAGED_FILES="+strings.Join(j.files, "\n"))
+	}
+	if hookName == "pre-push" && len(opts.HookArgs) >= 2 {
+		env = append(env,
+			"GHM_PUSH_REMOTE="+opts.HookArgs[0],
+			"GHM_PUSH_URL="+opts.HookArgs[1],
+		)
+	}
+	if opts.CI {
+		env = append(env, "GHM_CI=true")
+	}
+	if opts.Version != "" {
+		env = append(env, "GHM_VERSION="+opts.Version)
+	}
+	return env
+}
+
+// commandEnv returns the environment of a command: ghm's own environment
+// followed by the additions from envAdditions.
+func commandEnv(hookName string, j job, opts Options, filesPath string) ([]string, error) {
+	additions, err := envAdditions(hookName, j, opts, filesPath)
+	if err != nil {
+		return nil, err
+	}
+	return append(os.Environ(), additions...), nil
+}
+
+// envAdditions returns the variables a command gets on top of ghm's own
+// environment: the GHM_* variables, then the command's env_file and env
+// entries. ${NAME} references in env_file values see the variables before
+// them; references in env values see everything except other env entries.
+func envAdditions(hookName string, j job, opts Options, filesPath string) ([]string, error) {
+	command := j.command
+	env := builtinEnv(hookName, j, opts, filesPath)
+
+	values := make(map[string]string)
+	for _, kv := range append(os.Environ(), env...) {
+		name, value, _ := strings.Cut(kv, "=")
+		values[name] = value
+	}
+	lookup := func(name string) (string, bool) {
+		value, ok := values[name]
+		return value, ok
+	}
+
+	if command.EnvFile != "" {
+		vars, err := config.ReadEnvFile(filepath.Join(opts.RepoRoot, filepath.FromSlash(command.EnvFile)))
+		if err != nil {
+			return nil, fmt.Errorf("reading env_file: %w", err)
+		}
+		for _, v := range vars {
+			value := v.Value
+			if !v.Literal {
+				value = config.ExpandEnv(value, lookup)
+			}
+			values[v.Name] = value
+			env = append(env, v.Name+"="+value)
+		}
+	}
+
+	var extra []string
+	for _, v := range command.Env {
+		extra = append(extra, v.Name+"="+config.ExpandEnv(v.Value, lookup))
+	}
+	return append(env, extra...), nil
+}
+
+// filesUnder returns the files inside dir, relative to dir. All paths use
+// forward slashes.
+func filesUnder(dir string, files []string) []string {
+	prefix := dir + "/"
+	var under []string
+	for _, f := range files {
+		if rel, ok := strings.CutPrefix(f, prefix); ok {
+			under = append(under, rel)
+		}
+	}
+	return under
+}
@@ -10,56 +10,96 @@ const maxOutputBytes = 10240 // 10KB
 
 // HookError contains structured information about a hook command failure.
 type HookError struct {
-	HookName   string
-	Command    string
-	ExitCode   int
-	Stdout     string
-	Stderr     string
-	TimedOut   bool
-	TimeoutDur time.Duration
+	HookName    string
+	ID          string // empty if the command has no id
+	Command     string
+	ExitCode    int
+	Stdout      string
+	Stderr      string
+	TimedOut    bool
+	TimeoutDur  time.Duration
+	ForceKilled bool // the command ignored SIGTERM and was killed after KillGrace
+	KillGrace   time.Duration
+	Attempts    []Attempt // every attempt in order when the command was retried
+}
+
+// Attempt records the outcome of one try of a retried command.
+type Attempt struct {
+	ExitCode int
+	TimedOut bool
+	Stdout   string
+	Stderr   string
+}
+
+// status describes how the attempt ended.
+func (a Attempt) status() string {
+	if a.TimedOut {
+		return "timed out"
+	}
+	return fmt.Sprintf("exit code %d", a.ExitCode)
 }
 
 // Error implements the error interface.
 func (e *HookError) Error() string {
+	var attempts string
+	if len(e.Attempts) > 1 {
+		attempts = fmt.Sprintf(" after %d attempts", len(e.Attempts))
+	}
 	if e.TimedOut {
-		return fmt.Sprintf("hook %q timed out after %s: %s", e.HookName, e.TimeoutDur, e.Command)
+		return fmt.Sprintf("hook %q timed out after %s%s: %s", e.HookName, e.TimeoutDur, attempts, e.Command)
 	}
-	return fmt.Sprintf("hook %q failed (exit code %d): %s", e.HookName, e.ExitCode, e.Command)
+	return fmt.Sprintf("hook %q failed (exit code %d)%s: %s", e.HookName, e.ExitCode, attempts, e.Command)
 }
 
 // FormatReport returns a structured, human-readable error report.
 func (e *HookError) FormatReport() string {
+	return e.formatReport("HOOK FAILED")
+}
+
+// FormatWarning returns the same report as FormatReport, headed as a
+// warning, for commands whose failure does not fail the hook.
+func (e *HookError) FormatWarning() string {
+	return e.formatReport("WARNING (does not fail the hook)")
+}
+
+func (e *HookError) formatReport(title string) string {
 	var b strings.Builder
 
 	b.WriteString("===========================================================\n")
-	b.WriteString(" HOOK FAILED\n")
+	b.WriteString(" " + title + "\n")
 	b.WriteString("===========================================================\n")
 	b.WriteString("\n")
 	b.WriteString(fmt.Sprintf("  Hook:      %s\n", e.HookName))
+	if e.ID != "" {
+		b.WriteString(fmt.Sprintf("  ID:        %s\n", e.ID))
+	}
 	b.WriteString(fmt.Sprintf("  Command:   %s\n", e.Command))
 
 	if e.TimedOut {
 		b.WriteString(fmt.Sprintf("  Status:    timed out after %s\n", e.TimeoutDur))
+		if e.ForceKilled {
+			b.WriteString(fmt.Sprintf("  Stopped:   force-killed after a %s grace period\n", e.KillGrace))
+		} else {
+			b.WriteString("  Stopped:   exited gracefully after SIGTERM\n")
+		}
 	} else {
 		b.WriteString(fmt.Sprintf("  Exit Code: %d\n", e.ExitCode))
 	}
 
-	stdout := truncate(e.Stdout)
-	if stdout != "" {
-		b.WriteString("\n")
-		b.WriteString("-----------------------------------------------------------\n")
-		b.WriteString(" STDOUT\n")
-		b.WriteString("-----------------------------------------------------------\n")
-		b.WriteString("  " + strings.ReplaceAll(strings.TrimRight(stdout, "\n"), "\n", "\n  ") + "\n")
-	}
-
-	stderr := truncate(e.Stderr)
-	if stderr != "" {
-		b.WriteString("\n")
-		b.WriteString("-----------------------------------------------------------\n")
-		b.WriteString(" STDERR\n")
-		b.WriteString("-----------------------------------------------------------\n")
-		b.WriteString("  " + strings.ReplaceAll(strings.TrimRight(stderr, "\n"), "\n", "\n  ") + "\n")
+	if len(e.Attempts) > 1 {
+		b.WriteString(fmt.Sprintf("  Attempts:  %d\n", len(e.Attempts)))
+		for i, a := range e.Attempts {
+			label := fmt.Sprintf("ATTEMPT %d (%s)", i+1, a.status())
+			if a.Stdout == "" && a.Stderr == "" {
+				writeSection(&b, label, "(no output)")
+				continue
+			}
+			writeSection(&b, label+" STDOUT", a.Stdout)
+			writeSection(&b, label+" STDERR", a.Stderr)
+		}
+	} else {
+		writeSection(&b, "STDOUT", e.Stdout)
+		writeSection(&b, "STDERR", e.Stderr)
 	}
 
 	b.WriteString("\n")
@@ -74,6 +114,20 @@ func (e *HookError) FormatReport() string {
 	return b.String()
 }
 
+// writeSection writes a titled, indented block of output, truncated to
+// maxOutputBytes. Nothing is written if the output is empty.
+func writeSection(b *strings.Builder, title, output string) {
+	output = truncate(output)
+	if output == "" {
+		return
+	}
+	b.WriteString("\n")
+	b.WriteString("-----------------------------------------------------------\n")
+	b.WriteString(" " + title + "\n")
+	b.WriteString("-----------------------------------------------------------\n")
+	b.WriteString("  " + strings.ReplaceAll(strings.TrimRight(output, "\n"), "\n", "\n  ") + "\n")
+}
+
 // truncate limits a string to maxOutputBytes, appending a truncation notice if needed.
 func truncate(s string) string {
 	if len(s) <= maxOutputBytes {
@@ -81,3 +135,26 @@ func truncate(s string) string {
 	}
 	return s[:maxOutputBytes] + "\n... (output truncated)"
 }
+
+// HookErrors collects the failures of several commands from the same run.
+type HookErrors []*HookError
+
+// Error implements the error interface.
+func (e HookErrors) Error() string {
+	if len(e) == 0 {
+		return "no hook errors"
+	}
+	return fmt.Sprintf("hook %q failed: %d commands failed", e[0].HookName, len(e))
+}
+
+// FormatReport returns the reports of all failed commands, one after another.
+func (e HookErrors) FormatReport() string {
+	var b strings.Builder
+	for i, hookErr := range e {
+		if i > 0 {
+			b.WriteString("\n")
+		}
+		b.WriteString(hookErr.FormatReport())
+	}
+	return b.String()
+}
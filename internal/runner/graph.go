@@ -0,0 +1,199 @@
+package runner
+
+import (
+	"bytes"
+	"log/slog"
+	"slices"
+	"sort"
+
+	"githookd/internal/config"
+)
+
+// usesNeeds reports whether any command declares needs, in which case the
+// hook runs as a dependency graph.
+func usesNeeds(commands []config.ResolvedHookCommand) bool {
+	for _, command := range commands {
+		if len(command.Needs) > 0 {
+			return true
+		}
+	}
+	return false
+}
+
+// finished carries the result and buffered output of a job in a graph run.
+type finished struct {
+	index          int
+	result         Result
+	stdout, stderr *bytes.Buffer
+}
+
+// runGraph runs jobs as a dependency graph and records their results. A
+// job starts once every command it needs has passed, with at most
+// opts.MaxParallel running at a time; among ready jobs, configuration
+// order decides. Needed commands that were skipped count as passed, and so
+// do commands that failed with on_failure warn. Commands not marked
+// parallel also keep their configuration order relative to each other:
+// each one starts only after the previous one has finished or been
+// skipped, whatever its outcome. When a command fails, the commands that
+// depend on it, directly or not, are skipped with reason "dependency
+// failed". After a failure of a command with on_failure abort, or once ghm
+// is interrupted, no further jobs start. Output is buffered and printed as
+// each job finishes.
+func runGraph(hookName string, commands []config.ResolvedHookCommand, jobs []job, opts Options, results []Result) {
+	ids := make(map[string]int)
+	for i, command := range commands {
+		if command.ID != "" {
+			ids[command.ID] = i
+		}
+	}
+
+	scheduled := make(map[int]job, len(jobs))
+	for _, j := range jobs {
+		scheduled[j.index] = j
+	}
+
+	// dependents must pass for a job to run; followers are later
+	// sequential jobs that only wait for it to finish. waiting holds the
+	// number of both that every job not started yet still waits for.
+	dependents := make(map[int][]int)
+	followers := make(map[int][]int)
+	waiting := make(map[int]int, len(jobs))
+	for _, j := range jobs {
+		waiting[j.index] = 0
+		for _, need := range j.command.Needs {
+			d, ok := ids[need]
+			if _, run := scheduled[d]; !ok || !run || slices.Contains(dependents[d], j.index) {
+				continue
+			}
+			dependents[d] = append(dependents[d], j.index)
+			waiting[j.index]++
+		}
+	}
+
+	// reaches reports whether to must wait for from, directly or not
+	reaches := func(from, to int) bool {
+		seen := map[int]bool{from: true}
+		queue := []int{from}
+		for len(queue) > 0 {
+			index := queue[0]
+			queue = queue[1:]
+			if index == to {
+				return true
+			}
+			for _, next := range append(slices.Clip(dependents[index]), followers[index]...) {
+				if !seen[next] {
+					seen[next] = true
+					queue = append(queue, next)
+				}
+			}
+		}
+		return false
+	}
+
+	// Order each sequential job after the earlier ones, unless needs
+	// already order them the other way round. Going from the latest one
+	// back, an earlier job that already reaches it needs no edge of its
+	// own, which keeps the edges few.
+	var sequential []int
+	for _, j := range jobs {
+		if j.command.Parallel {
+			continue
+		}
+		for _, k := range slices.Backward(sequential) {
+			if !reaches(j.index, k) && !reaches(k, j.index) {
+				followers[k] = append(followers[k], j.index)
+				waiting[j.index]++
+			}
+		}
+		sequential = append(sequential, j.index)
+	}
+
+	var ready []int
+	for _, j := range jobs {
+		if waiting[j.index] == 0 {
+			ready = append(ready, j.index)
+		}
+	}
+
+	release := func(index int) {
+		if _, ok := waiting[index]; !ok {
+			return
+		}
+		waiting[index]--
+		if waiting[index] == 0 {
+			ready = append(ready, index)
+		}
+	}
+
+	// complete releases the jobs waiting for index once it has finished or
+	// been skipped. Unless it passed, the jobs that need it are skipped.
+	var complete func(index int, passed bool)
+	complete = func(index int, passed bool) {
+		for _, d := range dependents[index] {
+			if _, ok := waiting[d]; !ok {
+				continue
+			}
+			if passed {
+				release(d)
+				continue
+			}
+			delete(waiting, d)
+			slog.Info("Skipping command whose dependency failed", "hook", hookName, "command", commands[d].Run)
+			results[d].Reason = "dependency failed"
+			complete(d, false)
+		}
+		for _, d := range followers[index] {
+			release(d)
+		}
+	}
+
+	limit := opts.MaxParallel
+	if limit < 1 {
+		limit = 1
+	}
+	done := make(chan finished)
+	running := 0
+	stopped := false
+
+	for {
+		for !stopped && running < limit && len(ready) > 0 {
+			j := scheduled[ready[0]]
+			ready = ready[1:]
+			delete(waiting, j.index)
+			running++
+
+			slog.Info("Running command", "hook", hookName, "command", j.command.Run, "needs", j.command.Needs)
+			if j.command.Description != "" {
+				slog.Info("Description", "description", j.command.Description)
+			}
+			go func(j job) {
+				var stdoutBuf, stderrBuf bytes.Buffer
+				result := runJob(hookName, j, opts, &stdoutBuf, &stderrBuf)
+				done <- finished{index: j.index, result: result, stdout: &stdoutBuf, stderr: &stderrBuf}
+			}(j)
+		}
+		if running == 0 {
+			return
+		}
+
+		f := <-done
+		running--
+		writeOutput(f.result, f.stdout, f.stderr)
+
+		result := f.result
+		if opts.procs.interrupted() != nil {
+			stopped = true
+			if result.Status != StatusPassed {
+				result.Status = StatusInterrupted
+			}
+		}
+		results[f.index] = result
+
+		failed := result.Status == StatusFailed || result.Status == StatusInterrupted
+		if failed && result.Command.OnFailure == config.OnFailureAbort {
+			stopped = true
+		}
+		complete(f.index, !failed)
+		sort.Ints(ready)
+	}
+}
@@ -0,0 +1,125 @@
+package runner
+
+import (
+	"fmt"
+	"regexp"
+	"strings"
+	"time"
+
+	"githookd/internal/config"
+)
+
+// Plan describes what RunHook would do with a command, without running it.
+type Plan struct {
+	Command config.ResolvedHookCommand
+	Skipped bool
+	Reason  string        // why the command would be skipped
+	Argv    [][]string    // one argv per invocation
+	Dir     string        // absolute working directory
+	Env     []string      // variables added to ghm's own environment
+	Timeout time.Duration // 0 means no timeout
+	Files   []string      // files the command would receive
+	Error   string        // why the command would fail before starting, such as a missing env_file
+}
+
+// PlanHook returns a plan for every command of a hook, in configuration
+// order. It applies the same skip rules, file selection, and when and
+// except conditions as RunHook; shell conditions are run, but commands
+// are not.
+func PlanHook(hookName string, commands []config.ResolvedHookCommand, opts Options) []Plan {
+	results := make([]Result, len(commands))
+	plans := make([]Plan, len(commands))
+	for i, command := range commands {
+		results[i] = Result{Command: command, Status: StatusSkipped}
+		plans[i] = Plan{Command: command, Skipped: true}
+	}
+
+	for _, j := range selectJobs(hookName, commands, opts, results) {
+		plans[j.index] = planJob(hookName, j, opts)
+	}
+	for i := range plans {
+		if plans[i].Skipped {
+			plans[i].Reason = results[i].Reason
+		}
+	}
+	return plans
+}
+
+// planJob works out how runCommand would start a command.
+func planJob(hookName string, j job, opts Options) Plan {
+	p := Plan{Command: j.command, Timeout: j.command.Timeout, Files: j.files}
+
+	for _, script := range commandScripts(hookName, j, opts) {
+		p.Argv = append(p.Argv, commandArgv(j.command, script, opts))
+	}
+
+	var err error
+	p.Dir, err = commandDir(opts.RepoRoot, j.command.Dir)
+	if err == nil {
+		p.Env, err = envAdditions(hookName, j, opts, "<temporary file>")
+	}
+	if err != nil {
+		p.Error = err.Error()
+	}
+	return p
+}
+
+// FormatPlans returns a human-readable listing of a hook's plans.
+func FormatPlans(hookName string, plans []Plan) string {
+	var b strings.Builder
+	b.WriteString(fmt.Sprintf("%s (dry run)\n", hookName))
+	for _, p := range plans {
+		b.WriteString("\n")
+		if p.Skipped {
+			b.WriteString(fmt.Sprintf("  %-40s skipped (%s)\n", p.Command.Name(), p.Reason))
+			continue
+		}
+		b.WriteString(fmt.Sprintf("  %-40s would run\n", p.Command.Name()))
+		for _, argv := range p.Argv {
+			b.WriteString(fmt.Sprintf("    argv:     %s\n", FormatArgv(argv)))
+		}
+		b.WriteString(fmt.Sprintf("    dir:      %s\n", p.Dir))
+		if len(p.Command.Needs) > 0 {
+			b.WriteString(fmt.Sprintf("    needs:    %s\n", strings.Join(p.Command.Needs, ", ")))
+		}
+		b.WriteString(fmt.Sprintf("    timeout:  %s\n", FormatTimeout(p.Timeout)))
+		if len(p.Files) > 0 {
+			b.WriteString(fmt.Sprintf("    files:    %s\n", strings.Join(p.Files, ", ")))
+		}
+		if p.Error != "" {
+			b.WriteString(fmt.Sprintf("    error:    %s\n", p.Error))
+		}
+		if len(p.Env) > 0 {
+			b.WriteString("    env:\n")
+			for _, kv := range p.Env {
+				b.WriteString("      " + strings.ReplaceAll(kv, "\n", `\n`) + "\n")
+			}
+		}
+	}
+	return b.String()
+}
+
+// FormatTimeout returns a timeout as written in the config.
+func FormatTimeout(d time.Duration) string {
+	if d == 0 {
+		return "none"
+	}
+	return d.String()
+}
+
+// safeArg matches arguments that need no quoting in a shell.
+var safeArg = regexp.MustCompile(`^[A-Za-z0-9_@%+=:,./-]+$`)
+
+// FormatArgv joins an argv into a string that could be pasted into a
+// shell, quoting only the arguments that need it.
+func FormatArgv(argv []string) string {
+	quoted := make([]string, len(argv))
+	for i, arg := range argv {
+		if safeArg.MatchString(arg) {
+			quoted[i] = arg
+		} else {
+			quoted[i] = shellQuote(arg)
+		}
+	}
+	return strings.Join(quoted, " ")
+}
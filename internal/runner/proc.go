@@ -0,0 +1,184 @@
+package runner
+
+import (
+	"errors"
+	"log/slog"
+	"os"
+	"os/exec"
+	"sync"
+	"syscall"
+	"time"
+)
+
+// ErrInterrupted is returned by RunHook when a signal from Options.Interrupt
+// stopped the run.
+var ErrInterrupted = errors.New("interrupted")
+
+// stopper stops a command's process group when its context is done or ghm
+// is interrupted. It signals the group first and kills it if it is still
+// running after the grace period.
+type stopper struct {
+	cmd    *exec.Cmd
+	grace  time.Duration
+	mu     sync.Mutex
+	timer  *time.Timer
+	forced bool
+}
+
+// minWaitDelay is the shortest time Wait waits for the command's output
+// to be closed after the command has exited.
+const minWaitDelay = time.Second
+
+// newStopper starts cmd in its own process group and arranges for the
+// group to be stopped when the command's context is done. Once the command
+// has exited, its output is read for at most the grace period, so that a
+// process that left the group and holds stdout or stderr open cannot keep
+// Wait from returning.
+func newStopper(cmd *exec.Cmd, grace time.Duration) *stopper {
+	s := &stopper{cmd: cmd, grace: grace}
+	setProcessGroup(cmd)
+	cmd.Cancel = func() error {
+		s.signal(syscall.SIGTERM)
+		return nil
+	}
+	cmd.WaitDelay = max(grace, minWaitDelay)
+	return s
+}
+
+// signal sends sig to the process group and schedules it to be killed
+// after the grace period. A grace period of zero kills it at once.
+func (s *stopper) signal(sig os.Signal) {
+	if s.grace == 0 {
+		s.kill()
+		return
+	}
+
+	slog.Debug("Signalling process group", "pid", s.cmd.Process.Pid, "signal", sig, "grace", s.grace)
+	if err := signalGroup(s.cmd, sig); err != nil {
+		slog.Debug("Failed to signal process group", "pid", s.cmd.Process.Pid, "error", err)
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.timer == nil {
+		s.timer = time.AfterFunc(s.grace, s.kill)
+	}
+}
+
+// kill force-kills the process group.
+func (s *stopper) kill() {
+	s.mu.Lock()
+	s.forced = true
+	s.mu.Unlock()
+
+	slog.Debug("Killing process group", "pid", s.cmd.Process.Pid)
+	if err := killGroup(s.cmd); err != nil {
+		slog.Debug("Failed to kill process group", "pid", s.cmd.Process.Pid, "error", err)
+	}
+}
+
+// stop cancels a pending kill once the command has exited and reports
+// whether the group had to be force-killed.
+func (s *stopper) stop() bool {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.timer != nil {
+		s.timer.Stop()
+	}
+	return s.forced
+}
+
+// processes tracks the commands that are running so that signals received
+// by ghm can be forwarded to their process groups. Once interrupted, no
+// further commands are started.
+type processes struct {
+	mu      sync.Mutex
+	running map[*stopper]struct{}
+	sig     os.Signal     // first signal received; nil until interrupted
+	stopped chan struct{} // closed when interrupted
+}
+
+func newProcesses() *processes {
+	return &processes{running: make(map[*stopper]struct{}), stopped: make(chan struct{})}
+}
+
+// watch forwards signals from ch to the running commands until done is
+// closed. A second signal kills the commands without waiting for the grace
+// period.
+func (p *processes) watch(ch <-chan os.Signal, done <-chan struct{}) {
+	for {
+		select {
+		case sig := <-ch:
+			p.forward(sig)
+		case <-done:
+			return
+		}
+	}
+}
+
+// forward records the interruption and passes sig on to every running
+// command, or kills them if ghm was already interrupted.
+func (p *processes) forward(sig os.Signal) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	again := p.sig != nil
+	if !again {
+		p.sig = sig
+		close(p.stopped)
+	}
+	slog.Info("Interrupted; stopping running commands", "signal", sig, "running", len(p.running))
+	for s := range p.running {
+		if again {
+			s.kill()
+		} else {
+			s.signal(sig)
+		}
+	}
+}
+
+// interrupted returns the signal that interrupted the run, or nil.
+func (p *processes) interrupted() os.Signal {
+	if p == nil {
+		return nil
+	}
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	return p.sig
+}
+
+// done returns a channel that is closed once the run is interrupted.
+func (p *processes) done() <-chan struct{} {
+	if p == nil {
+		return nil
+	}
+	return p.stopped
+}
+
+// run starts the stopper's command, tracks it while it runs and waits for
+// it to exit. It returns ErrInterrupted without starting the command if
+// the run was already interrupted.
+func (p *processes) run(s *stopper) error {
+	if p == nil {
+		return s.cmd.Run()
+	}
+
+	p.mu.Lock()
+	if p.sig != nil {
+		p.mu.Unlock()
+		return ErrInterrupted
+	}
+	if err := s.cmd.Start(); err != nil {
+		p.mu.Unlock()
+		return err
+	}
+	p.running[s] = struct{}{}
+	p.mu.Unlock()
+
+	err := s.cmd.Wait()
+
+	p.mu.Lock()
+	delete(p.running, s)
+	p.mu.Unlock()
+	return err
+}
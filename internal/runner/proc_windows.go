@@ -0,0 +1,28 @@
+//go:build windows
+
+package runner
+
+import (
+	"os"
+	"os/exec"
+	"strconv"
+	"syscall"
+)
+
+// setProcessGroup starts the command in a new process group so that it and
+// all of its children can be stopped together.
+func setProcessGroup(cmd *exec.Cmd) {
+	cmd.SysProcAttr = &syscall.SysProcAttr{CreationFlags: syscall.CREATE_NEW_PROCESS_GROUP}
+}
+
+// signalGroup asks the command's process tree to exit. Windows cannot
+// deliver Unix signals, so sig is ignored and taskkill without /F sends a
+// close request instead.
+func signalGroup(cmd *exec.Cmd, sig os.Signal) error {
+	return exec.Command("taskkill", "/T", "/PID", strconv.Itoa(cmd.Process.Pid)).Run()
+}
+
+// killGroup forcibly kills the command's process tree.
+func killGroup(cmd *exec.Cmd) error {
+	return exec.Command("taskkill", "/T", "/F", "/PID", strconv.Itoa(cmd.Process.Pid)).Run()
+}
@@ -3,45 +3,446 @@ package runner
 import (
 	"bytes"
 	"context"
+	"errors"
 	"fmt"
 	"io"
 	"log/slog"
 	"os"
 	"os/exec"
+	"path/filepath"
+	"slices"
 	"strings"
+	"sync"
 	"time"
 
 	"githookd/internal/config"
+	"githookd/internal/git"
 )
 
-// RunHook executes all enabled commands for a hook in sequence.
-// Returns on first failure (abort semantics).
-func RunHook(hookName string, commands []config.ResolvedHookCommand, repoRoot string, hookArgs []string) error {
-	for _, command := range commands {
+// Options controls how RunHook executes a hook's commands.
+type Options struct {
+	RepoRoot    string
+	HookArgs    []string
+	MaxParallel int      // upper bound on concurrently running commands; < 1 means 1
+	Stdin       []byte   // data Git passed on stdin, replayed to every command
+	Files       []string // files the hook applies to, relative to RepoRoot
+	Staged      bool     // Files are the staged files, so stage_fixed may re-stage them
+	Skip        []SkipRule
+	Only        []string // if set, only commands matching one of these selectors run
+	Branch      string   // current branch; empty when HEAD is detached
+	CI          bool     // ghm run was started directly in a CI environment
+	Version     string   // ghm version, passed to commands as GHM_VERSION
+
+	// Interrupt delivers signals received by ghm, such as SIGINT. Each is
+	// forwarded to the process groups of the running commands, and no
+	// further commands are started.
+	Interrupt <-chan os.Signal
+
+	procs *processes // set by RunHook
+
+	// pushed holds the files changed by the commits a pre-push hook is
+	// sending, used in place of Files when it is not set. Set by
+	// selectJobs.
+	pushed []string
+
+	// unstaged holds the files that had unstaged changes before the run,
+	// which stage_fixed must not stage. Set by RunHook; nil when unknown.
+	unstaged map[string]bool
+}
+
+// SkipRule skips the commands matching Selector (an id or run string) and
+// records Reason, such as "GHM_SKIP", in the summary.
+type SkipRule struct {
+	Selector string
+	Reason   string
+}
+
+// job is an enabled command together with the files it applies to and its
+// position in the hook's command list.
+type job struct {
+	index   int
+	command config.ResolvedHookCommand
+	files   []string
+}
+
+// stageMu serializes updates to the index from concurrently running commands.
+var stageMu sync.Mutex
+
+// RunHook executes all enabled commands for a hook. Consecutive commands
+// marked parallel run concurrently as a group; all other commands run in
+// sequence. If any command declares needs, the hook instead runs as a
+// dependency graph (see runGraph). By default execution stops after the
+// first failing command or group (abort semantics). Failures of commands
+// with on_failure continue let the remaining commands run, and failures of
+// commands with on_failure warn are reported as warnings only. The
+// returned error covers every failure in the run, or is ErrInterrupted if
+// a signal from opts.Interrupt stopped it. The summary records the outcome
+// of every command, including skipped ones.
+func RunHook(hookName string, commands []config.ResolvedHookCommand, opts Options) (*Summary, error) {
+	summary := &Summary{HookName: hookName, Results: make([]Result, len(commands))}
+	for i, command := range commands {
+		summary.Results[i] = Result{Command: command, Status: StatusSkipped, Reason: "not run"}
+	}
+
+	opts.procs = newProcesses()
+	if opts.Interrupt != nil {
+		done := make(chan struct{})
+		defer close(done)
+		go opts.procs.watch(opts.Interrupt, done)
+	}
+
+	jobs := selectJobs(hookName, commands, opts, summary.Results)
+	if opts.Staged && usesStageFixed(jobs) {
+		opts.unstaged = unstagedFiles(opts.RepoRoot)
+	}
+	if usesNeeds(commands) {
+		runGraph(hookName, commands, jobs, opts, summary.Results)
+	} else {
+		runGroups(hookName, groupJobs(jobs), opts, summary.Results)
+	}
+
+	if sig := opts.procs.interrupted(); sig != nil {
+		summary.Interrupted = sig
+		return summary, ErrInterrupted
+	}
+	return summary, summary.Err()
+}
+
+// runGroups runs execution groups one after another and records their
+// results. It stops after a group in which a command with on_failure abort
+// failed, or once ghm is interrupted.
+func runGroups(hookName string, groups [][]job, opts Options, results []Result) {
+	for _, group := range groups {
+		var groupResults []Result
+		if len(group) == 1 {
+			groupResults = runSequential(hookName, group[0], opts)
+		} else {
+			groupResults = runParallel(hookName, group, opts)
+		}
+
+		sig := opts.procs.interrupted()
+		abort := false
+		for i, result := range groupResults {
+			if sig != nil && result.Status != StatusPassed {
+				result.Status = StatusInterrupted
+			}
+			results[group[i].index] = result
+			if result.Status == StatusFailed && result.Command.OnFailure == config.OnFailureAbort {
+				abort = true
+			}
+		}
+		if sig != nil || abort {
+			return
+		}
+	}
+}
+
+// selectJobs returns the commands that should run. Disabled commands,
+// commands excluded by skip rules or their when conditions, and commands
+// with no matching files are skipped and their reason recorded in results.
+// Files are matched against opts.Files or, for a pre-push hook without
+// them, the files the pushed commits change. Conditions are evaluated
+// before any command runs.
+func selectJobs(hookName string, commands []config.ResolvedHookCommand, opts Options, results []Result) []job {
+	var jobs []job
+
+	fileSet := opts.Files
+	if hookName == "pre-push" && opts.Files == nil && (usesChangedPaths(commands) || slices.ContainsFunc(commands, usesFiles)) {
+		opts.pushed = pushedFiles(opts.RepoRoot, opts.Stdin)
+		fileSet = opts.pushed
+	}
+
+	for i, command := range commands {
 		if !command.Enabled {
 			slog.Info("Skipping disabled command", "hook", hookName, "command", command.Run)
+			results[i].Reason = "disabled"
 			continue
 		}
 
-		slog.Info("Running command", "hook", hookName, "command", command.Run)
-		if command.Description != "" {
-			slog.Info("Description", "description", command.Description)
+		if reason, skip := skipReason(command, opts); skip {
+			slog.Info("Skipping command", "hook", hookName, "command", command.Run, "reason", reason)
+			results[i].Reason = reason
+			continue
 		}
 
-		hookErr := runCommand(hookName, command, repoRoot, hookArgs)
-		if hookErr != nil {
-			return hookErr
+		files, ok := selectFiles(command, fileSet)
+		if !ok {
+			slog.Info("Skipping command with no matching files", "hook", hookName, "command", command.Run)
+			results[i].Reason = "no matching files"
+			continue
+		}
+		if ok, why := checkCondition(hookName, command, opts); !ok {
+			slog.Info("Skipping command", "hook", hookName, "command", command.Run, "reason", why)
+			results[i].Reason = why
+			continue
+		} else if command.When != nil || command.Except != nil {
+			slog.Debug("Conditions met", "hook", hookName, "command", command.Run, "reason", why)
+		}
+
+		jobs = append(jobs, job{index: i, command: command, files: files})
+	}
+	return jobs
+}
+
+// groupJobs splits jobs into execution groups. A group is either a single
+// sequential command or a run of adjacent parallel ones.
+func groupJobs(jobs []job) [][]job {
+	var groups [][]job
+	var current []job
+
+	for _, j := range jobs {
+		if !j.command.Parallel {
+			if len(current) > 0 {
+				groups = append(groups, current)
+				current = nil
+			}
+			groups = append(groups, []job{j})
+			continue
+		}
+		current = append(current, j)
+	}
+
+	if len(current) > 0 {
+		groups = append(groups, current)
+	}
+	return groups
+}
+
+// skipReason reports whether a command is excluded by a skip rule or by
+// not matching the --only selectors, and the reason to record.
+func skipReason(command config.ResolvedHookCommand, opts Options) (string, bool) {
+	for _, rule := range opts.Skip {
+		if command.Matches(rule.Selector) {
+			return rule.Reason, true
+		}
+	}
+
+	if len(opts.Only) == 0 {
+		return "", false
+	}
+	for _, selector := range opts.Only {
+		if command.Matches(selector) {
+			return "", false
+		}
+	}
+	return "--only", true
+}
+
+// selectFiles returns the files a command applies to and whether it should
+// run at all. For a command with a dir, only files inside it are selected,
+// relative to it. A command with a file filter, or one that references
+// {staged_files}, is skipped when no file matches.
+func selectFiles(command config.ResolvedHookCommand, files []string) ([]string, bool) {
+	selected := files
+	if command.Files != nil {
+		selected = command.Files.Filter(files)
+	}
+	if command.Dir != "" {
+		selected = filesUnder(command.Dir, selected)
+	}
+
+	if usesFiles(command) && len(selected) == 0 {
+		return nil, false
+	}
+	return selected, true
+}
+
+// usesFiles reports whether a command works on files: it has a file
+// filter or references {staged_files}.
+func usesFiles(command config.ResolvedHookCommand) bool {
+	return command.Files != nil || countPlaceholder(command.Run, "staged_files") > 0
+}
+
+// runSequential runs a single command, streaming its output as it is
+// produced. The output of on_failure warn commands is buffered instead, so
+// that it can be shown inside the warning block if the command fails.
+func runSequential(hookName string, j job, opts Options) []Result {
+	slog.Info("Running command", "hook", hookName, "command", j.command.Run)
+	if j.command.Description != "" {
+		slog.Info("Description", "description", j.command.Description)
+	}
+
+	if j.command.OnFailure != config.OnFailureWarn {
+		return []Result{runJob(hookName, j, opts, os.Stdout, os.Stderr)}
+	}
+
+	var stdoutBuf, stderrBuf bytes.Buffer
+	result := runJob(hookName, j, opts, &stdoutBuf, &stderrBuf)
+	writeOutput(result, &stdoutBuf, &stderrBuf)
+	return []Result{result}
+}
+
+// runParallel runs a group of commands concurrently, bounded by
+// opts.MaxParallel. Each command's output is buffered and printed as a
+// single block once it finishes so that output from different commands
+// never interleaves. Results are returned in configuration order.
+func runParallel(hookName string, group []job, opts Options) []Result {
+	limit := opts.MaxParallel
+	if limit < 1 {
+		limit = 1
+	}
+	sem := make(chan struct{}, limit)
+
+	results := make([]Result, len(group))
+	var wg sync.WaitGroup
+	var outputMu sync.Mutex // serializes the output blocks
+
+	for i, j := range group {
+		wg.Add(1)
+		go func(i int, j job) {
+			defer wg.Done()
+			sem <- struct{}{}
+			defer func() { <-sem }()
+
+			slog.Info("Running command", "hook", hookName, "command", j.command.Run, "parallel", true)
+			if j.command.Description != "" {
+				slog.Info("Description", "description", j.command.Description)
+			}
+
+			var stdoutBuf, stderrBuf bytes.Buffer
+			results[i] = runJob(hookName, j, opts, &stdoutBuf, &stderrBuf)
+
+			outputMu.Lock()
+			defer outputMu.Unlock()
+			writeOutput(results[i], &stdoutBuf, &stderrBuf)
+		}(i, j)
+	}
+	wg.Wait()
+
+	return results
+}
+
+// writeOutput prints a command's buffered output. A warned command's
+// output is printed as a warning block on stderr instead.
+func writeOutput(result Result, stdout, stderr *bytes.Buffer) {
+	if result.Status == StatusWarned {
+		os.Stderr.WriteString(result.Err.FormatWarning())
+		return
+	}
+	os.Stdout.Write(stdout.Bytes())
+	os.Stderr.Write(stderr.Bytes())
+}
+
+// runJob runs a command and records its result. For stage_fixed commands,
+// the staged files passed to the command that it modified are added back to
+// the index once it succeeds, except files that also had unstaged changes,
+// which staging would commit. Failures of on_failure warn commands are
+// recorded as warnings.
+func runJob(hookName string, j job, opts Options, stdout, stderr io.Writer) Result {
+	result := Result{Command: j.command}
+
+	var before map[string]string
+	if j.command.StageFixed && opts.Staged {
+		before = snapshotFiles(opts.RepoRoot, rootFiles(j))
+	}
+
+	var hookErr *HookError
+	start := time.Now()
+	result.Attempts, hookErr = runAttempts(hookName, j, opts, stdout, stderr)
+	result.Elapsed = time.Since(start)
+
+	if hookErr != nil {
+		result.Status = StatusFailed
+		if j.command.OnFailure == config.OnFailureWarn {
+			result.Status = StatusWarned
+			slog.Warn("Command failed with on_failure warn; continuing", "hook", hookName, "command", j.command.Run)
+		}
+		result.Err = hookErr
+		return result
+	}
+	result.Status = StatusPassed
+
+	if before != nil {
+		changed, partial := splitPartial(changedFiles(opts.RepoRoot, before), opts.unstaged)
+		if len(partial) > 0 {
+			slog.Warn("Not re-staging modified files that have unstaged changes", "hook", hookName, "command", j.command.Run, "files", partial)
+			result.Unstaged = partial
+		}
+		if len(changed) > 0 {
+			slog.Info("Re-staging modified files", "hook", hookName, "command", j.command.Run, "files", changed)
+			stageMu.Lock()
+			err := git.AddFiles(opts.RepoRoot, changed)
+			stageMu.Unlock()
+			if err != nil {
+				result.Status = StatusFailed
+				if j.command.OnFailure == config.OnFailureWarn {
+					result.Status = StatusWarned
+				}
+				result.Err = &HookError{
+					HookName: hookName,
+					ID:       j.command.ID,
+					Command:  j.command.Run,
+					ExitCode: 1,
+					Stderr:   fmt.Sprintf("failed to re-stage modified files: %v\n", err),
+				}
+				return result
+			}
+			result.Restaged = changed
+		}
+	}
+
+	return result
+}
+
+// runAttempts runs a command until it succeeds or its retries are used up,
+// waiting retry_delay between attempts and multiplying the delay by
+// retry_backoff after each one. Timeouts are only retried when the command
+// sets retry_on_timeout. It returns the number of attempts made, and the
+// returned error records every one of them.
+func runAttempts(hookName string, j job, opts Options, stdout, stderr io.Writer) (int, *HookError) {
+	command := j.command
+	delay := command.RetryDelay
+	var attempts []Attempt
+
+	for n := 1; ; n++ {
+		hookErr := runCommand(hookName, j, opts, stdout, stderr)
+		if hookErr == nil {
+			if n > 1 {
+				slog.Info("Command succeeded after retrying", "hook", hookName, "command", command.Run, "attempts", n)
+			}
+			return n, nil
+		}
+
+		if opts.procs.interrupted() != nil {
+			return n, hookErr
+		}
+
+		attempts = append(attempts, Attempt{
+			ExitCode: hookErr.ExitCode,
+			TimedOut: hookErr.TimedOut,
+			Stdout:   hookErr.Stdout,
+			Stderr:   hookErr.Stderr,
+		})
+		if n > command.Retries || (hookErr.TimedOut && !command.RetryOnTimeout) {
+			hookErr.Attempts = attempts
+			return n, hookErr
+		}
+
+		slog.Warn("Command failed; retrying", "hook", hookName, "command", command.Run,
+			"attempt", n, "retries", command.Retries, "delay", delay)
+		select {
+		case <-opts.procs.done():
+			return n, hookErr
+		case <-time.After(delay):
+		}
+		if command.RetryBackoff > 1 {
+			delay = time.Duration(float64(delay) * command.RetryBackoff)
 		}
 	}
-	return nil
 }
 
 // runCommand executes a single hook command with timeout and output capture.
-func runCommand(hookName string, command config.ResolvedHookCommand, repoRoot string, hookArgs []string) *HookError {
-	// Build the shell command with hook arguments
-	script := command.Run
-	if len(hookArgs) > 0 {
-		script += " " + strings.Join(hookArgs, " ")
+// Each invocation runs in its own process group; on timeout the group is
+// sent SIGTERM and, if it is still running after kill_grace, SIGKILL.
+// Output is copied to stdout and stderr as it is produced. When the file
+// list is too long for one invocation, the command is run once per batch of
+// files and stops at the first failing batch.
+func runCommand(hookName string, j job, opts Options, stdout, stderr io.Writer) *HookError {
+	command := j.command
+
+	scripts := commandScripts(hookName, j, opts)
+	if len(scripts) > 1 {
+		slog.Debug("Splitting file list across invocations", "invocations", len(scripts), "files", len(j.files))
 	}
 
 	// Set up context with timeout
@@ -57,25 +458,61 @@ func runCommand(hookName string, command config.ResolvedHookCommand, repoRoot st
 	}
 	defer cancel()
 
-	cmd := exec.CommandContext(ctx, "sh", "-c", script)
-	cmd.Dir = repoRoot
-	cmd.Env = append(os.Environ(),
-		"GHM_HOOK_NAME="+hookName,
-		"GHM_ROOT="+repoRoot,
-	)
-
-	slog.Debug("Execution environment",
-		"dir", repoRoot,
-		"script", script,
-	)
+	dir, err := commandDir(opts.RepoRoot, command.Dir)
+	var filesPath string
+	if err == nil && fileListTooLong(j.files) {
+		filesPath, err = writeFileList(j.files)
+		if err == nil {
+			defer os.Remove(filesPath)
+		}
+	}
+	var env []string
+	if err == nil {
+		env, err = commandEnv(hookName, j, opts, filesPath)
+	}
+	if err != nil {
+		return &HookError{
+			HookName: hookName,
+			ID:       command.ID,
+			Command:  command.Run,
+			ExitCode: 1,
+			Stderr:   err.Error() + "\n",
+		}
+	}
 
 	// Stream and capture stdout/stderr
 	var stdoutBuf, stderrBuf bytes.Buffer
-	cmd.Stdout = io.MultiWriter(os.Stdout, &stdoutBuf)
-	cmd.Stderr = io.MultiWriter(os.Stderr, &stderrBuf)
 
 	start := time.Now()
-	err := cmd.Run()
+	var forced bool
+	for _, script := range scripts {
+		argv := commandArgv(command, script, opts)
+		cmd := exec.CommandContext(ctx, argv[0], argv[1:]...)
+		cmd.Dir = dir
+		if opts.Stdin != nil {
+			cmd.Stdin = bytes.NewReader(opts.Stdin)
+		}
+		cmd.Env = env
+		cmd.Stdout = io.MultiWriter(stdout, &stdoutBuf)
+		cmd.Stderr = io.MultiWriter(stderr, &stderrBuf)
+		stop := newStopper(cmd, command.KillGrace)
+
+		slog.Debug("Execution environment",
+			"dir", dir,
+			"argv", argv,
+		)
+
+		err = opts.procs.run(stop)
+		forced = stop.stop()
+		if errors.Is(err, exec.ErrWaitDelay) {
+			// The command exited, but left a process holding its output
+			slog.Warn("Command exited with its output still open; stopped reading it", "command", command.Run)
+			err = nil
+		}
+		if err != nil {
+			break
+		}
+	}
 	elapsed := time.Since(start)
 
 	slog.Debug("Command completed", "elapsed", elapsed)
@@ -83,6 +520,7 @@ func runCommand(hookName string, command config.ResolvedHookCommand, repoRoot st
 	if err != nil {
 		hookErr := &HookError{
 			HookName: hookName,
+			ID:       command.ID,
 			Command:  command.Run,
 			Stdout:   stdoutBuf.String(),
 			Stderr:   stderrBuf.String(),
@@ -92,6 +530,8 @@ func runCommand(hookName string, command config.ResolvedHookCommand, repoRoot st
 		if ctx.Err() == context.DeadlineExceeded {
 			hookErr.TimedOut = true
 			hookErr.TimeoutDur = command.Timeout
+			hookErr.ForceKilled = forced
+			hookErr.KillGrace = command.KillGrace
 		} else {
 			// Extract exit code
 			if exitErr, ok := err.(*exec.ExitError); ok {
@@ -107,12 +547,97 @@ func runCommand(hookName string, command config.ResolvedHookCommand, repoRoot st
 	return nil
 }
 
+// commandScripts expands the placeholders in a command's run string, once
+// per batch of files. Hook arguments are also passed as positional
+// parameters so that $1, $2, ... refer to them without re-parsing. Script
+// files are run as they are, once.
+func commandScripts(hookName string, j job, opts Options) []string {
+	if j.command.Script != "" {
+		return []string{j.command.Run}
+	}
+	return expandScripts(j.command.Run, map[string][]string{
+		"args": opts.HookArgs,
+		"hook": {hookName},
+		"root": {opts.RepoRoot},
+	}, j.files, quoteFunc(j.command.Shell))
+}
+
 // FormatErrors formats multiple config validation errors into a single string.
+// Errors located in a config file show the offending line with a caret under
+// the column, and a suggested fix when there is one. The header names the
+// config file when every located error is in the same one. Files under the
+// current directory are shown relative to it.
 func FormatErrors(errs []error) string {
+	var files []string
+	for _, err := range errs {
+		if cerr, ok := err.(*config.Error); ok && cerr.Pos.File != "" && !slices.Contains(files, cerr.Pos.File) {
+			files = append(files, cerr.Pos.File)
+		}
+	}
+
 	var b strings.Builder
-	b.WriteString("Error: invalid configuration in .githooksrc.yml\n\n")
+	if len(files) == 1 {
+		b.WriteString(fmt.Sprintf("Error: invalid configuration in %s\n\n", relativeToCwd(files[0])))
+	} else {
+		b.WriteString("Error: invalid configuration\n\n")
+	}
 	for _, err := range errs {
-		b.WriteString(fmt.Sprintf("  - %s\n", err))
+		cerr, ok := err.(*config.Error)
+		if !ok || cerr.Pos.Line == 0 {
+			b.WriteString(fmt.Sprintf("  - %s\n", err))
+			continue
+		}
+		pos := cerr.Pos
+		pos.File = relativeToCwd(pos.File)
+		b.WriteString(fmt.Sprintf("  - %s: %s\n", pos, cerr.Err))
+		writeSnippet(&b, cerr)
 	}
 	return b.String()
 }
+
+// writeSnippet writes the source line of a located error with a caret
+// under its column, followed by its hint.
+func writeSnippet(b *strings.Builder, err *config.Error) {
+	if err.Source == "" && err.Hint == "" {
+		return
+	}
+	num := fmt.Sprint(err.Pos.Line)
+	gutter := strings.Repeat(" ", len(num))
+	if err.Source != "" {
+		// Keep tabs so the caret lines up under the column, which counts
+		// characters rather than bytes
+		var pad strings.Builder
+		for i, r := range []rune(err.Source) {
+			if i >= err.Pos.Column-1 {
+				break
+			}
+			if r == '\t' {
+				pad.WriteRune('\t')
+			} else {
+				pad.WriteByte(' ')
+			}
+		}
+		fmt.Fprintf(b, "    %s |\n", gutter)
+		fmt.Fprintf(b, "    %s | %s\n", num, err.Source)
+		fmt.Fprintf(b, "    %s | %s^\n", gutter, pad.String())
+	}
+	if err.Hint != "" {
+		fmt.Fprintf(b, "    %s = %s\n", gutter, err.Hint)
+	}
+}
+
+// relativeToCwd returns path relative to the current directory when it is
+// inside it.
+func relativeToCwd(path string) string {
+	if !filepath.IsAbs(path) {
+		return path
+	}
+	wd, err := os.Getwd()
+	if err != nil {
+		return path
+	}
+	if rel, err := filepath.Rel(wd, path); err == nil && filepath.IsLocal(rel) {
+		return rel
+	}
+	return path
+}
@@ -1,9 +1,18 @@
 package runner
 
 import (
+	"errors"
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"runtime"
 	"strings"
 	"testing"
 	"time"
+
+	"githookd/internal/config"
+	"githookd/internal/git"
 )
 
 func TestHookError_Error(t *testing.T) {
@@ -68,6 +77,18 @@ func TestHookError_FormatReport(t *testing.T) {
 	}
 }
 
+func TestHookError_FormatReport_ID(t *testing.T) {
+	err := &HookError{HookName: "pre-commit", ID: "lint", Command: "npm run lint", ExitCode: 1}
+	if report := err.FormatReport(); !strings.Contains(report, "ID:        lint") {
+		t.Errorf("FormatReport() missing id, got:\n%s", report)
+	}
+
+	err.ID = ""
+	if report := err.FormatReport(); strings.Contains(report, "ID:") {
+		t.Errorf("FormatReport() should omit the id line when there is none, got:\n%s", report)
+	}
+}
+
 func TestHookError_FormatReport_Timeout(t *testing.T) {
 	err := &HookError{
 		HookName:   "pre-commit",
@@ -120,10 +141,6 @@ func TestTruncate(t *testing.T) {
 
 func TestFormatErrors(t *testing.T) {
 	errs := []error{
-		strings.NewReader("").(*strings.Reader), // This won't work, use fmt.Errorf
-	}
-	// Use proper errors
-	errs = []error{
 		&HookError{HookName: "test", Command: "cmd", ExitCode: 1},
 	}
 
@@ -132,3 +149,1340 @@ func TestFormatErrors(t *testing.T) {
 		t.Errorf("FormatErrors() = %q, want 'invalid configuration'", got)
 	}
 }
+
+func TestFormatErrors_Snippet(t *testing.T) {
+	errs := []error{
+		&config.Error{
+			Pos:    config.Position{File: "/elsewhere/.githooksrc.yml", Line: 12, Column: 7},
+			Err:    fmt.Errorf(`unknown key "desciption" in command`),
+			Source: "      desciption: lint",
+			Hint:   `did you mean "description"?`,
+		},
+		fmt.Errorf("invalid max_parallel -1: must be positive"),
+	}
+
+	want := `Error: invalid configuration in /elsewhere/.githooksrc.yml
+
+  - /elsewhere/.githooksrc.yml:12:7: unknown key "desciption" in command
+       |
+    12 |       desciption: lint
+       |       ^
+       = did you mean "description"?
+  - invalid max_parallel -1: must be positive
+`
+	if got := FormatErrors(errs); got != want {
+		t.Errorf("FormatErrors() =\n%s\nwant:\n%s", got, want)
+	}
+}
+
+func TestFormatErrors_SeveralFilesAndWideCharacters(t *testing.T) {
+	errs := []error{
+		&config.Error{
+			Pos:    config.Position{File: "/shared/base.yml", Line: 3, Column: 21},
+			Err:    fmt.Errorf(`invalid timeout "soon"`),
+			Source: `    - run: "héllo" # timeout: soon`,
+		},
+		&config.Error{
+			Pos: config.Position{File: "/repo/.githooksrc.local.yml", Line: 1, Column: 1},
+			Err: fmt.Errorf(`invalid global log_level "loud"`),
+		},
+	}
+
+	got := FormatErrors(errs)
+	if !strings.HasPrefix(got, "Error: invalid configuration\n") {
+		t.Errorf("header should not name a single file, got:\n%s", got)
+	}
+	// The caret sits under the 21st character, not the 21st byte
+	if !strings.Contains(got, "\n      | "+strings.Repeat(" ", 20)+"^\n") {
+		t.Errorf("caret misplaced, got:\n%s", got)
+	}
+}
+
+func TestHookErrors_FormatReport(t *testing.T) {
+	errs := HookErrors{
+		{HookName: "pre-commit", Command: "npm run lint", ExitCode: 1},
+		{HookName: "pre-commit", Command: "npm run test", ExitCode: 2},
+	}
+
+	if got := errs.Error(); !strings.Contains(got, "2 commands failed") {
+		t.Errorf("Error() = %q, want it to mention '2 commands failed'", got)
+	}
+
+	report := errs.FormatReport()
+	for _, check := range []string{"npm run lint", "npm run test", "Exit Code: 2"} {
+		if !strings.Contains(report, check) {
+			t.Errorf("FormatReport() missing %q", check)
+		}
+	}
+}
+
+func TestSelectAndGroupJobs(t *testing.T) {
+	commands := []config.ResolvedHookCommand{
+		{Run: "a", Enabled: true},
+		{Run: "b", Enabled: true, Parallel: true},
+		{Run: "c", Enabled: true, Parallel: true},
+		{Run: "d", Enabled: false, Parallel: true},
+		{Run: "e", Enabled: true},
+		{Run: "f", Enabled: true, Parallel: true},
+	}
+
+	results := make([]Result, len(commands))
+	groups := groupJobs(selectJobs("pre-commit", commands, Options{}, results))
+
+	want := [][]string{{"a"}, {"b", "c"}, {"e"}, {"f"}}
+	if len(groups) != len(want) {
+		t.Fatalf("got %d groups, want %d", len(groups), len(want))
+	}
+	for i, group := range groups {
+		var runs []string
+		for _, j := range group {
+			runs = append(runs, j.command.Run)
+		}
+		if strings.Join(runs, ",") != strings.Join(want[i], ",") {
+			t.Errorf("group %d = %v, want %v", i, runs, want[i])
+		}
+	}
+	if results[3].Reason != "disabled" {
+		t.Errorf("disabled command reason = %q, want %q", results[3].Reason, "disabled")
+	}
+}
+
+func TestRunHook_ParallelRunsConcurrently(t *testing.T) {
+	commands := []config.ResolvedHookCommand{
+		{Run: "sleep 0.5", Enabled: true, Parallel: true, Timeout: 5 * time.Second},
+		{Run: "sleep 0.5", Enabled: true, Parallel: true, Timeout: 5 * time.Second},
+		{Run: "sleep 0.5", Enabled: true, Parallel: true, Timeout: 5 * time.Second},
+	}
+
+	start := time.Now()
+	_, err := RunHook("pre-commit", commands, Options{RepoRoot: t.TempDir(), MaxParallel: 3})
+	if err != nil {
+		t.Fatalf("RunHook() error = %v", err)
+	}
+	if elapsed := time.Since(start); elapsed > 1200*time.Millisecond {
+		t.Errorf("parallel commands took %v, want them to overlap", elapsed)
+	}
+}
+
+func TestRunHook_ParallelCollectsAllFailures(t *testing.T) {
+	commands := []config.ResolvedHookCommand{
+		{Run: "exit 3", Enabled: true, Parallel: true, Timeout: 5 * time.Second},
+		{Run: "true", Enabled: true, Parallel: true, Timeout: 5 * time.Second},
+		{Run: "exit 4", Enabled: true, Parallel: true, Timeout: 5 * time.Second},
+		{Run: "touch never-run", Enabled: true, Timeout: 5 * time.Second},
+	}
+
+	dir := t.TempDir()
+	_, err := RunHook("pre-commit", commands, Options{RepoRoot: dir, MaxParallel: 2})
+
+	errs, ok := err.(HookErrors)
+	if !ok {
+		t.Fatalf("RunHook() error = %T %v, want HookErrors", err, err)
+	}
+	if len(errs) != 2 {
+		t.Fatalf("got %d failures, want 2", len(errs))
+	}
+	if errs[0].ExitCode != 3 || errs[1].ExitCode != 4 {
+		t.Errorf("exit codes = %d, %d; want 3, 4 in config order", errs[0].ExitCode, errs[1].ExitCode)
+	}
+	if _, statErr := os.Stat(filepath.Join(dir, "never-run")); statErr == nil {
+		t.Error("command after a failed parallel group should not run")
+	}
+}
+
+func TestRunHook_SequentialAbortsOnFirstFailure(t *testing.T) {
+	commands := []config.ResolvedHookCommand{
+		{Run: "exit 1", Enabled: true, Timeout: 5 * time.Second},
+		{Run: "exit 2", Enabled: true, Timeout: 5 * time.Second},
+	}
+
+	_, err := RunHook("pre-commit", commands, Options{RepoRoot: t.TempDir()})
+	hookErr, ok := err.(*HookError)
+	if !ok {
+		t.Fatalf("RunHook() error = %T %v, want *HookError", err, err)
+	}
+	if hookErr.ExitCode != 1 {
+		t.Errorf("ExitCode = %d, want 1", hookErr.ExitCode)
+	}
+}
+
+func TestRunHook_OnFailureContinue(t *testing.T) {
+	commands := []config.ResolvedHookCommand{
+		{Run: "exit 1", Enabled: true, Timeout: 5 * time.Second, OnFailure: config.OnFailureContinue},
+		{Run: "true", Enabled: true, Timeout: 5 * time.Second},
+		{Run: "exit 2", Enabled: true, Timeout: 5 * time.Second},
+		{Run: "true", Enabled: true, Timeout: 5 * time.Second},
+	}
+
+	summary, err := RunHook("pre-commit", commands, Options{RepoRoot: t.TempDir()})
+	hookErrs, ok := err.(HookErrors)
+	if !ok || len(hookErrs) != 2 {
+		t.Fatalf("RunHook() error = %T %v, want HookErrors with 2 failures", err, err)
+	}
+
+	want := []Status{StatusFailed, StatusPassed, StatusFailed, StatusSkipped}
+	for i, result := range summary.Results {
+		if result.Status != want[i] {
+			t.Errorf("command #%d status = %s, want %s", i+1, result.Status, want[i])
+		}
+	}
+}
+
+func TestRunHook_OnFailureWarn(t *testing.T) {
+	commands := []config.ResolvedHookCommand{
+		{Run: "echo too many TODOs; exit 1", Enabled: true, Timeout: 5 * time.Second, OnFailure: config.OnFailureWarn},
+		{Run: "true", Enabled: true, Timeout: 5 * time.Second},
+	}
+
+	summary, err := RunHook("pre-commit", commands, Options{RepoRoot: t.TempDir()})
+	if err != nil {
+		t.Fatalf("RunHook() error = %v, want nil", err)
+	}
+
+	warned := summary.Results[0]
+	if warned.Status != StatusWarned {
+		t.Fatalf("status = %s, want warned", warned.Status)
+	}
+	if warned.Err == nil || !strings.Contains(warned.Err.Stdout, "too many TODOs") {
+		t.Errorf("warning should keep the command output, got %+v", warned.Err)
+	}
+	if summary.Results[1].Status != StatusPassed {
+		t.Errorf("second command status = %s, want passed", summary.Results[1].Status)
+	}
+	if got := summary.Format(); !strings.Contains(got, "1 passed, 0 failed, 1 warned, 0 skipped") {
+		t.Errorf("Format() missing warned count, got:\n%s", got)
+	}
+}
+
+func TestHookError_FormatWarning(t *testing.T) {
+	err := &HookError{HookName: "pre-commit", Command: "todo-check", ExitCode: 1, Stdout: "12 TODOs\n"}
+
+	report := err.FormatWarning()
+	if !strings.Contains(report, "WARNING") || strings.Contains(report, "HOOK FAILED") {
+		t.Errorf("FormatWarning() should be headed as a warning, got:\n%s", report)
+	}
+	if !strings.Contains(report, "12 TODOs") {
+		t.Errorf("FormatWarning() missing output, got:\n%s", report)
+	}
+}
+
+func TestRunHook_RetriesUntilSuccess(t *testing.T) {
+	dir := t.TempDir()
+	// Fails on the first two attempts, succeeds on the third.
+	commands := []config.ResolvedHookCommand{
+		{Run: "echo x >> attempts; test $(wc -l < attempts) -ge 3", Enabled: true, Timeout: 5 * time.Second, Retries: 3, RetryDelay: time.Millisecond, RetryBackoff: 2},
+	}
+
+	summary, err := RunHook("pre-push", commands, Options{RepoRoot: dir})
+	if err != nil {
+		t.Fatalf("RunHook() error = %v", err)
+	}
+	if got := summary.Results[0].Attempts; got != 3 {
+		t.Errorf("Attempts = %d, want 3", got)
+	}
+	if got := summary.Format(); !strings.Contains(got, "3 attempts") {
+		t.Errorf("Format() missing attempt count, got:\n%s", got)
+	}
+}
+
+func TestRunHook_RetriesExhausted(t *testing.T) {
+	commands := []config.ResolvedHookCommand{
+		{Run: "echo flaky; exit 1", Enabled: true, Timeout: 5 * time.Second, Retries: 2},
+	}
+
+	_, err := RunHook("pre-push", commands, Options{RepoRoot: t.TempDir()})
+	hookErr, ok := err.(*HookError)
+	if !ok {
+		t.Fatalf("RunHook() error = %T %v, want *HookError", err, err)
+	}
+	if len(hookErr.Attempts) != 3 {
+		t.Fatalf("len(Attempts) = %d, want 3", len(hookErr.Attempts))
+	}
+	if !strings.Contains(hookErr.Error(), "after 3 attempts") {
+		t.Errorf("Error() = %q, want it to mention the attempts", hookErr.Error())
+	}
+
+	report := hookErr.FormatReport()
+	for _, check := range []string{"Attempts:  3", "ATTEMPT 1 (exit code 1) STDOUT", "ATTEMPT 3 (exit code 1) STDOUT", "flaky"} {
+		if !strings.Contains(report, check) {
+			t.Errorf("FormatReport() missing %q, got:\n%s", check, report)
+		}
+	}
+}
+
+func TestRunHook_TimeoutNotRetriedByDefault(t *testing.T) {
+	commands := []config.ResolvedHookCommand{
+		{Run: "exec sleep 5", Enabled: true, Timeout: 50 * time.Millisecond, Retries: 2},
+	}
+
+	_, err := RunHook("pre-push", commands, Options{RepoRoot: t.TempDir()})
+	hookErr, ok := err.(*HookError)
+	if !ok || !hookErr.TimedOut {
+		t.Fatalf("RunHook() error = %v, want a timeout", err)
+	}
+	if len(hookErr.Attempts) != 1 {
+		t.Errorf("len(Attempts) = %d, want 1", len(hookErr.Attempts))
+	}
+
+	commands[0].RetryOnTimeout = true
+	_, err = RunHook("pre-push", commands, Options{RepoRoot: t.TempDir()})
+	if hookErr, ok := err.(*HookError); !ok || len(hookErr.Attempts) != 3 {
+		t.Errorf("RunHook() error = %v, want 3 timed out attempts", err)
+	}
+}
+
+func TestRunHook_TimeoutStopsProcessGroup(t *testing.T) {
+	tests := []struct {
+		name   string
+		run    string
+		forced bool
+	}{
+		{"graceful", "sleep 10 & sleep 10; wait", false},
+		{"force-killed", "trap '' TERM; sleep 10 & sleep 10; wait", true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			commands := []config.ResolvedHookCommand{
+				{Run: tt.run, Enabled: true, Timeout: 100 * time.Millisecond, KillGrace: 200 * time.Millisecond},
+			}
+
+			start := time.Now()
+			_, err := RunHook("pre-push", commands, Options{RepoRoot: t.TempDir()})
+			if elapsed := time.Since(start); elapsed > 3*time.Second {
+				t.Errorf("RunHook() took %s; children outlived the timeout", elapsed)
+			}
+
+			hookErr, ok := err.(*HookError)
+			if !ok || !hookErr.TimedOut {
+				t.Fatalf("RunHook() error = %v, want a timeout", err)
+			}
+			if hookErr.ForceKilled != tt.forced {
+				t.Errorf("ForceKilled = %v, want %v", hookErr.ForceKilled, tt.forced)
+			}
+
+			want := "exited gracefully"
+			if tt.forced {
+				want = "force-killed after a 200ms grace period"
+			}
+			if report := hookErr.FormatReport(); !strings.Contains(report, want) {
+				t.Errorf("FormatReport() missing %q, got:\n%s", want, report)
+			}
+		})
+	}
+}
+
+func TestRunHook_OutputHeldOpenAfterExit(t *testing.T) {
+	setsid, err := exec.LookPath("setsid")
+	if err != nil {
+		t.Skip("setsid not available")
+	}
+	tests := []struct {
+		name    string
+		run     string
+		timeout bool
+	}{
+		{"exited", "sleep 10 & echo done", false},
+		{"timed out", setsid + " sleep 10 & wait", true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			commands := []config.ResolvedHookCommand{
+				{Run: tt.run, Enabled: true, Timeout: 300 * time.Millisecond, KillGrace: 200 * time.Millisecond},
+			}
+
+			start := time.Now()
+			summary, err := RunHook("pre-push", commands, Options{RepoRoot: t.TempDir()})
+			if elapsed := time.Since(start); elapsed > 5*time.Second {
+				t.Errorf("RunHook() took %s; waited for output held open by a leftover process", elapsed)
+			}
+			if hookErr, ok := err.(*HookError); ok != tt.timeout || (ok && !hookErr.TimedOut) {
+				t.Errorf("RunHook() error = %v, want timeout %v", err, tt.timeout)
+			}
+			if !tt.timeout && summary.Results[0].Status != StatusPassed {
+				t.Errorf("status = %s, want passed", summary.Results[0].Status)
+			}
+		})
+	}
+}
+
+func TestRunHook_InterruptForwardedToCommands(t *testing.T) {
+	commands := []config.ResolvedHookCommand{
+		{Run: "sleep 10", Enabled: true, Timeout: 30 * time.Second, KillGrace: 5 * time.Second},
+		{Run: "true", Enabled: true, Timeout: 30 * time.Second},
+	}
+
+	interrupt := make(chan os.Signal, 1)
+	time.AfterFunc(200*time.Millisecond, func() { interrupt <- os.Interrupt })
+
+	start := time.Now()
+	summary, err := RunHook("pre-push", commands, Options{RepoRoot: t.TempDir(), Interrupt: interrupt})
+	if elapsed := time.Since(start); elapsed > 3*time.Second {
+		t.Errorf("RunHook() took %s; the signal was not forwarded", elapsed)
+	}
+	if !errors.Is(err, ErrInterrupted) {
+		t.Fatalf("RunHook() error = %v, want ErrInterrupted", err)
+	}
+	if summary.Interrupted != os.Interrupt {
+		t.Errorf("Interrupted = %v, want %v", summary.Interrupted, os.Interrupt)
+	}
+	if got := summary.Results[0].Status; got != StatusInterrupted {
+		t.Errorf("first command status = %s, want interrupted", got)
+	}
+	if got := summary.Results[1]; got.Status != StatusSkipped || got.Reason != "not run" {
+		t.Errorf("second command = %s (%s), want skipped (not run)", got.Status, got.Reason)
+	}
+	if got := summary.Format(); !strings.Contains(got, "1 interrupted") || !strings.Contains(got, "interrupted by interrupt") {
+		t.Errorf("Format() missing interruption, got:\n%s", got)
+	}
+}
+
+func TestRunHook_InterruptDuringRetryDelay(t *testing.T) {
+	commands := []config.ResolvedHookCommand{
+		{Run: "exit 1", Enabled: true, Timeout: 5 * time.Second, Retries: 3, RetryDelay: 10 * time.Second},
+	}
+
+	interrupt := make(chan os.Signal, 1)
+	time.AfterFunc(200*time.Millisecond, func() { interrupt <- os.Interrupt })
+
+	start := time.Now()
+	summary, err := RunHook("pre-push", commands, Options{RepoRoot: t.TempDir(), Interrupt: interrupt})
+	if elapsed := time.Since(start); elapsed > 3*time.Second {
+		t.Errorf("RunHook() took %s; the retry delay ignored the interrupt", elapsed)
+	}
+	if !errors.Is(err, ErrInterrupted) {
+		t.Fatalf("RunHook() error = %v, want ErrInterrupted", err)
+	}
+	if got := summary.Results[0]; got.Status != StatusInterrupted || got.Attempts != 1 {
+		t.Errorf("result = %s after %d attempts, want interrupted after 1", got.Status, got.Attempts)
+	}
+}
+
+func TestRunHook_DirAndEnv(t *testing.T) {
+	root := t.TempDir()
+	if err := os.MkdirAll(filepath.Join(root, "services", "api"), 0o755); err != nil {
+		t.Fatal(err)
+	}
+	envFile := "BASE=${GHM_HOOK_NAME}-base\nRAW='${BASE}'\n"
+	if err := os.WriteFile(filepath.Join(root, ".env.test"), []byte(envFile), 0o644); err != nil {
+		t.Fatal(err)
+	}
+
+	commands := []config.ResolvedHookCommand{
+		{
+			Run:     `printf '%s|%s|%s|%s' "$(pwd -P)" "$BASE" "$RAW" "$DERIVED" > out.txt`,
+			Enabled: true,
+			Timeout: 5 * time.Second,
+			Dir:     "services/api",
+			EnvFile: ".env.test",
+			Env:     []config.EnvVar{{Name: "DERIVED", Value: "${BASE}/x"}},
+		},
+	}
+
+	if _, err := RunHook("pre-commit", commands, Options{RepoRoot: root}); err != nil {
+		t.Fatalf("RunHook() error = %v", err)
+	}
+
+	out, err := os.ReadFile(filepath.Join(root, "services", "api", "out.txt"))
+	if err != nil {
+		t.Fatalf("command did not run in dir: %v", err)
+	}
+	wantDir, _ := filepath.EvalSymlinks(filepath.Join(root, "services", "api"))
+	want := wantDir + "|pre-commit-base|${BASE}|pre-commit-base/x"
+	if string(out) != want {
+		t.Errorf("output = %q, want %q", out, want)
+	}
+}
+
+func TestRunHook_DirOutsideRepo(t *testing.T) {
+	root := t.TempDir()
+	if err := os.Symlink(t.TempDir(), filepath.Join(root, "escape")); err != nil {
+		t.Fatal(err)
+	}
+
+	commands := []config.ResolvedHookCommand{
+		{Run: "true", Enabled: true, Timeout: 5 * time.Second, Dir: "escape"},
+	}
+	_, err := RunHook("pre-commit", commands, Options{RepoRoot: root})
+	hookErr, ok := err.(*HookError)
+	if !ok || !strings.Contains(hookErr.Stderr, "outside the repository") {
+		t.Errorf("RunHook() error = %v, want a dir outside the repository error", err)
+	}
+}
+
+func TestSelectFiles_Dir(t *testing.T) {
+	command := config.ResolvedHookCommand{Run: "gofmt -l {staged_files}", Dir: "services/api"}
+	got, ok := selectFiles(command, []string{"services/api/main.go", "services/web/app.js", "README.md"})
+	if !ok || len(got) != 1 || got[0] != "main.go" {
+		t.Errorf("selectFiles() = %v, %v; want [main.go], true", got, ok)
+	}
+
+	if _, ok := selectFiles(command, []string{"README.md"}); ok {
+		t.Error("selectFiles() should skip a command with no files inside its dir")
+	}
+}
+
+func TestBuiltinEnv(t *testing.T) {
+	j := job{command: config.ResolvedHookCommand{ID: "lint", Run: "lint"}, files: []string{"a.go", "b.go"}}
+	opts := Options{
+		RepoRoot: "/repo",
+		HookArgs: []string{"origin", "git@example.com:org/repo.git"},
+		Branch:   "main",
+		CI:       true,
+		Version:  "1.2.3",
+	}
+
+	env := strings.Join(builtinEnv("pre-push", j, opts, ""), "\n")
+	for _, want := range []string{
+		"GHM_HOOK_NAME=pre-push",
+		"GHM_ROOT=/repo",
+		"GHM_HOOK_ARGS='origin' 'git@example.com:org/repo.git'",
+		"GHM_COMMAND_ID=lint",
+		"GHM_BRANCH=main",
+		"GHM_STAGED_FILES=a.go\nb.go",
+		"GHM_PUSH_REMOTE=origin",
+		"GHM_PUSH_URL=git@example.com:org/repo.git",
+		"GHM_CI=true",
+		"GHM_VERSION=1.2.3",
+	} {
+		if !strings.Contains(env, want) {
+			t.Errorf("builtinEnv() missing %q, got:\n%s", want, env)
+		}
+	}
+
+	env = strings.Join(builtinEnv("pre-commit", j, Options{}, ""), "\n")
+	for _, unwanted := range []string{"GHM_PUSH_REMOTE", "GHM_CI", "GHM_VERSION"} {
+		if strings.Contains(env, unwanted) {
+			t.Errorf("builtinEnv() should not set %s, got:\n%s", unwanted, env)
+		}
+	}
+}
+
+func TestRunHook_LongFileListInTempFile(t *testing.T) {
+	old := maxEnvFilesLen
+	maxEnvFilesLen = 10
+	defer func() { maxEnvFilesLen = old }()
+
+	root := t.TempDir()
+	commands := []config.ResolvedHookCommand{
+		{Run: `test -z "$GHM_STAGED_FILES" && cat "$GHM_STAGED_FILES_FILE" > list.txt`, Enabled: true, Timeout: 5 * time.Second},
+	}
+	files := []string{"first.go", "second.go"}
+	if _, err := RunHook("pre-commit", commands, Options{RepoRoot: root, Files: files}); err != nil {
+		t.Fatalf("RunHook() error = %v", err)
+	}
+
+	got, err := os.ReadFile(filepath.Join(root, "list.txt"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(got) != "first.go\nsecond.go\n" {
+		t.Errorf("file list = %q, want both files one per line", got)
+	}
+}
+
+func TestPlanHook(t *testing.T) {
+	goFiles, _ := config.NewFileFilter([]string{"*.go"}, nil, nil)
+	commands := []config.ResolvedHookCommand{
+		{ID: "lint", Run: "lint", Enabled: true},
+		{Run: "gofmt -l {staged_files}", Enabled: true, Files: goFiles},
+		{Run: "off", Enabled: false},
+	}
+
+	root := t.TempDir()
+	commands[0].Timeout = 30 * time.Second
+	commands[0].Env = []config.EnvVar{{Name: "LINT_ROOT", Value: "${GHM_ROOT}/src"}}
+	plans := PlanHook("pre-commit", commands, Options{RepoRoot: root, HookArgs: []string{"x"}, Files: []string{"README.md"}})
+	if plans[0].Skipped || !strings.Contains(strings.Join(plans[0].Env, "\n"), "GHM_COMMAND_ID=lint") {
+		t.Errorf("plan 0 = %+v, want it to run with GHM_COMMAND_ID=lint", plans[0])
+	}
+	if got := plans[0].Env[len(plans[0].Env)-1]; got != "LINT_ROOT="+root+"/src" {
+		t.Errorf("last env addition = %q, want the expanded env entry", got)
+	}
+	if len(plans[0].Argv) != 1 || FormatArgv(plans[0].Argv[0]) != "sh -c lint ghm x" {
+		t.Errorf("Argv = %v, want [[sh -c lint ghm x]]", plans[0].Argv)
+	}
+	if plans[0].Dir != root || plans[0].Timeout != 30*time.Second || len(plans[0].Files) != 1 {
+		t.Errorf("plan 0 = %+v, want dir %s, 30s timeout and one file", plans[0], root)
+	}
+	if !plans[1].Skipped || plans[1].Reason != "no matching files" {
+		t.Errorf("plan 1 = %+v, want skipped (no matching files)", plans[1])
+	}
+	if !plans[2].Skipped || plans[2].Reason != "disabled" {
+		t.Errorf("plan 2 = %+v, want skipped (disabled)", plans[2])
+	}
+
+	out := FormatPlans("pre-commit", plans)
+	for _, want := range []string{"pre-commit (dry run)", "would run", "argv:     sh -c lint ghm x", "timeout:  30s", "files:    README.md", "GHM_BRANCH=", "skipped (disabled)"} {
+		if !strings.Contains(out, want) {
+			t.Errorf("FormatPlans() missing %q, got:\n%s", want, out)
+		}
+	}
+}
+
+func TestCommandArgv(t *testing.T) {
+	opts := Options{RepoRoot: "/repo", HookArgs: []string{"origin", "url"}}
+	tests := []struct {
+		name    string
+		command config.ResolvedHookCommand
+		want    string
+	}{
+		{"default", config.ResolvedHookCommand{}, "sh -c run ghm origin url"},
+		{"bash", config.ResolvedHookCommand{Shell: config.Shell{Name: "bash", Argv: []string{"bash", "-c"}}}, "bash -c run ghm origin url"},
+		{"pwsh", config.ResolvedHookCommand{Shell: config.Shell{Name: "pwsh", Argv: []string{"pwsh", "-Command"}}}, "pwsh -Command run"},
+		{"custom", config.ResolvedHookCommand{Shell: config.Shell{Argv: []string{"python3", "-c"}}}, "python3 -c run origin url"},
+		{"script", config.ResolvedHookCommand{Script: "/cfg/.githooks/lint.py", Interpreter: []string{"python3"}}, "python3 /cfg/.githooks/lint.py origin url"},
+		{"script without shebang", config.ResolvedHookCommand{Script: "/cfg/.githooks/lint.sh"}, "sh /cfg/.githooks/lint.sh origin url"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := strings.Join(commandArgv(tt.command, "run", opts), " "); got != tt.want {
+				t.Errorf("commandArgv() = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestRunHook_ScriptCommand(t *testing.T) {
+	root := t.TempDir()
+	// Scripts live next to the config file, which need not be at the root
+	scripts := filepath.Join(root, "cfg", ".githooks")
+	if err := os.MkdirAll(scripts, 0o755); err != nil {
+		t.Fatal(err)
+	}
+	script := "#!/bin/sh\necho \"$1 $GHM_HOOK_NAME\" > out.txt\n"
+	if err := os.WriteFile(filepath.Join(scripts, "check.sh"), []byte(script), 0o644); err != nil {
+		t.Fatal(err)
+	}
+
+	commands := []config.ResolvedHookCommand{
+		{Run: ".githooks/check.sh", Script: filepath.Join(scripts, "check.sh"), Interpreter: []string{"/bin/sh"}, Enabled: true, Timeout: 5 * time.Second},
+	}
+	if _, err := RunHook("commit-msg", commands, Options{RepoRoot: root, HookArgs: []string{".git/COMMIT_EDITMSG"}}); err != nil {
+		t.Fatalf("RunHook() error = %v", err)
+	}
+
+	got, err := os.ReadFile(filepath.Join(root, "out.txt"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(got) != ".git/COMMIT_EDITMSG commit-msg\n" {
+		t.Errorf("output = %q", got)
+	}
+}
+
+func TestFormatArgv(t *testing.T) {
+	got := FormatArgv([]string{"sh", "-c", "echo 'hi' there", "ghm", "", "a/b.go"})
+	want := `sh -c 'echo '\''hi'\'' there' ghm '' a/b.go`
+	if got != want {
+		t.Errorf("FormatArgv() = %s, want %s", got, want)
+	}
+}
+
+func TestRunHook_NeedsRunsAfterDependencies(t *testing.T) {
+	root := t.TempDir()
+	commands := []config.ResolvedHookCommand{
+		{ID: "test", Run: "test -f built && echo test >> order", Enabled: true, Timeout: 5 * time.Second, Needs: []string{"build"}},
+		{ID: "build", Run: "sleep 0.2; touch built; echo build >> order", Enabled: true, Timeout: 5 * time.Second},
+		{ID: "lint", Run: "echo lint >> order", Enabled: true, Parallel: true, Timeout: 5 * time.Second},
+	}
+
+	summary, err := RunHook("pre-push", commands, Options{RepoRoot: root, MaxParallel: 4})
+	if err != nil {
+		t.Fatalf("RunHook() error = %v", err)
+	}
+	for i, r := range summary.Results {
+		if r.Status != StatusPassed {
+			t.Errorf("command #%d status = %s, want passed", i+1, r.Status)
+		}
+	}
+
+	order, err := os.ReadFile(filepath.Join(root, "order"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	// lint is parallel with no dependencies, so it does not wait for build.
+	if got := strings.Fields(string(order)); strings.Join(got, " ") != "lint build test" {
+		t.Errorf("order = %v, want [lint build test]", got)
+	}
+}
+
+func TestRunHook_NeedsKeepsSequentialOrder(t *testing.T) {
+	root := t.TempDir()
+	commands := []config.ResolvedHookCommand{
+		{ID: "fmt", Run: "sleep 0.2; echo fmt >> order", Enabled: true, Timeout: 5 * time.Second},
+		{ID: "vet", Run: "echo vet >> order", Enabled: true, Timeout: 5 * time.Second, OnFailure: config.OnFailureContinue},
+		{ID: "gen", Run: "sleep 0.1; echo gen >> order", Enabled: true, Parallel: true, Timeout: 5 * time.Second},
+		{ID: "test", Run: "echo test >> order", Enabled: true, Timeout: 5 * time.Second, Needs: []string{"gen"}},
+	}
+
+	if _, err := RunHook("pre-push", commands, Options{RepoRoot: root, MaxParallel: 4}); err != nil {
+		t.Fatalf("RunHook() error = %v", err)
+	}
+	order, err := os.ReadFile(filepath.Join(root, "order"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	// gen runs alongside fmt; vet and test wait for the sequential
+	// commands before them.
+	if got := strings.Fields(string(order)); strings.Join(got, " ") != "gen fmt vet test" {
+		t.Errorf("order = %v, want [gen fmt vet test]", got)
+	}
+}
+
+func TestRunHook_NeedsWarnedDependencyCountsAsPassed(t *testing.T) {
+	commands := []config.ResolvedHookCommand{
+		{ID: "audit", Run: "exit 1", Enabled: true, Timeout: 5 * time.Second, OnFailure: config.OnFailureWarn},
+		{ID: "deploy", Run: "true", Enabled: true, Timeout: 5 * time.Second, Needs: []string{"audit"}},
+	}
+
+	summary, err := RunHook("pre-push", commands, Options{RepoRoot: t.TempDir()})
+	if err != nil {
+		t.Fatalf("RunHook() error = %v", err)
+	}
+	if got := summary.Results[0].Status; got != StatusWarned {
+		t.Errorf("audit status = %s, want warned", got)
+	}
+	if got := summary.Results[1].Status; got != StatusPassed {
+		t.Errorf("deploy status = %s, want passed", got)
+	}
+}
+
+func TestRunHook_NeedsSkipsDependentsOfFailure(t *testing.T) {
+	commands := []config.ResolvedHookCommand{
+		{ID: "build", Run: "exit 1", Enabled: true, Timeout: 5 * time.Second, OnFailure: config.OnFailureContinue},
+		{ID: "test", Run: "true", Enabled: true, Timeout: 5 * time.Second, Needs: []string{"build"}},
+		{ID: "e2e", Run: "true", Enabled: true, Timeout: 5 * time.Second, Needs: []string{"test"}},
+		{ID: "lint", Run: "true", Enabled: true, Timeout: 5 * time.Second},
+		{ID: "docs", Run: "true", Enabled: false},
+		{ID: "site", Run: "true", Enabled: true, Timeout: 5 * time.Second, Needs: []string{"docs"}},
+	}
+
+	summary, err := RunHook("pre-push", commands, Options{RepoRoot: t.TempDir(), MaxParallel: 1})
+	if _, ok := err.(*HookError); !ok {
+		t.Fatalf("RunHook() error = %T %v, want *HookError", err, err)
+	}
+
+	want := []struct {
+		status Status
+		reason string
+	}{
+		{StatusFailed, ""},
+		{StatusSkipped, "dependency failed"},
+		{StatusSkipped, "dependency failed"},
+		{StatusPassed, ""},
+		{StatusSkipped, "disabled"},
+		{StatusPassed, ""}, // a skipped dependency counts as satisfied
+	}
+	for i, r := range summary.Results {
+		if r.Status != want[i].status || r.Reason != want[i].reason {
+			t.Errorf("command #%d = %s (%s), want %s (%s)", i+1, r.Status, r.Reason, want[i].status, want[i].reason)
+		}
+	}
+	if got := summary.Format(); !strings.Contains(got, "skipped (dependency failed)") {
+		t.Errorf("Format() missing dependency failure, got:\n%s", got)
+	}
+}
+
+func TestRunHook_NeedsAbortStopsScheduling(t *testing.T) {
+	commands := []config.ResolvedHookCommand{
+		{ID: "build", Run: "exit 1", Enabled: true, Timeout: 5 * time.Second},
+		{ID: "lint", Run: "true", Enabled: true, Timeout: 5 * time.Second},
+		{ID: "test", Run: "true", Enabled: true, Timeout: 5 * time.Second, Needs: []string{"build"}},
+	}
+
+	summary, _ := RunHook("pre-push", commands, Options{RepoRoot: t.TempDir(), MaxParallel: 1})
+	if r := summary.Results[1]; r.Status != StatusSkipped || r.Reason != "not run" {
+		t.Errorf("lint = %s (%s), want skipped (not run)", r.Status, r.Reason)
+	}
+	if r := summary.Results[2]; r.Reason != "dependency failed" {
+		t.Errorf("test = %s (%s), want skipped (dependency failed)", r.Status, r.Reason)
+	}
+}
+
+func TestRunHook_NeedsSchedulesManySequentialCommandsQuickly(t *testing.T) {
+	// build fails and aborts, so the time taken is mostly scheduling
+	commands := []config.ResolvedHookCommand{
+		{ID: "build", Run: "exit 1", Enabled: true, Timeout: 5 * time.Second},
+	}
+	for i := range 40 {
+		commands = append(commands, config.ResolvedHookCommand{ID: fmt.Sprintf("step%d", i), Run: "true", Enabled: true, Timeout: 5 * time.Second})
+	}
+	commands[len(commands)-1].Needs = []string{"build"}
+
+	start := time.Now()
+	summary, _ := RunHook("pre-push", commands, Options{RepoRoot: t.TempDir(), MaxParallel: 4})
+	if elapsed := time.Since(start); elapsed > 2*time.Second {
+		t.Errorf("RunHook() took %v, want scheduling to be near-instant", elapsed)
+	}
+	if r := summary.Results[len(commands)-1]; r.Reason != "dependency failed" {
+		t.Errorf("last step = %s (%s), want skipped (dependency failed)", r.Status, r.Reason)
+	}
+}
+
+func TestRunHook_StdinReplayedToEveryCommand(t *testing.T) {
+	commands := []config.ResolvedHookCommand{
+		{Run: "cat > first", Enabled: true, Timeout: 5 * time.Second},
+		{Run: "cat > second", Enabled: true, Parallel: true, Timeout: 5 * time.Second},
+		{Run: "cat > third", Enabled: true, Parallel: true, Timeout: 5 * time.Second},
+	}
+
+	dir := t.TempDir()
+	input := "refs/heads/main 1111 refs/heads/main 2222\n"
+	_, err := RunHook("pre-push", commands, Options{RepoRoot: dir, MaxParallel: 2, Stdin: []byte(input)})
+	if err != nil {
+		t.Fatalf("RunHook() error = %v", err)
+	}
+
+	for _, name := range []string{"first", "second", "third"} {
+		data, err := os.ReadFile(filepath.Join(dir, name))
+		if err != nil {
+			t.Fatal(err)
+		}
+		if string(data) != input {
+			t.Errorf("%s received %q, want %q", name, data, input)
+		}
+	}
+}
+
+func TestShellQuote(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected string
+	}{
+		{"", "''"},
+		{"simple", "'simple'"},
+		{"with space", "'with space'"},
+		{"it's", `'it'\''s'`},
+		{"$(rm -rf /)", "'$(rm -rf /)'"},
+	}
+
+	for _, tt := range tests {
+		if got := shellQuote(tt.input); got != tt.expected {
+			t.Errorf("shellQuote(%q) = %q, want %q", tt.input, got, tt.expected)
+		}
+	}
+}
+
+func TestCommandScripts_QuotesForShell(t *testing.T) {
+	files := []string{"a'b.txt", "c’d.txt"}
+	tests := []struct {
+		shell config.Shell
+		want  string
+	}{
+		{config.DefaultShell, `fmt 'a'\''b.txt' 'c’d.txt'`},
+		{config.Shell{Name: "pwsh", Argv: []string{"pwsh", "-Command"}}, "fmt 'a''b.txt' 'c’’d.txt'"},
+		{config.Shell{Argv: []string{"powershell.exe", "-Command"}}, "fmt 'a''b.txt' 'c’’d.txt'"},
+	}
+	for _, tt := range tests {
+		j := job{command: config.ResolvedHookCommand{Run: "fmt {staged_files}", Shell: tt.shell}, files: files}
+		if got := commandScripts("pre-commit", j, Options{}); len(got) != 1 || got[0] != tt.want {
+			t.Errorf("commandScripts() with %v = %q, want [%q]", tt.shell.Argv, got, tt.want)
+		}
+	}
+}
+
+func TestExpandTemplate(t *testing.T) {
+	vars := map[string][]string{
+		"args": {"a b", "c"},
+		"hook": {"commit-msg"},
+		"root": {"/repo"},
+	}
+
+	tests := []struct {
+		input    string
+		expected string
+	}{
+		{"check {args}", "check 'a b' 'c'"},
+		{"echo {hook} in {root}", "echo 'commit-msg' in '/repo'"},
+		{"echo ${args} {unknown}", "echo ${args} {unknown}"},
+		{"echo {a,b} {args", "echo {a,b} {args"},
+	}
+
+	for _, tt := range tests {
+		if got := expandTemplate(tt.input, vars, shellQuote); got != tt.expected {
+			t.Errorf("expandTemplate(%q) = %q, want %q", tt.input, got, tt.expected)
+		}
+	}
+}
+
+func TestRunHook_ArgsArePositionalParameters(t *testing.T) {
+	dir := t.TempDir()
+	commands := []config.ResolvedHookCommand{
+		{Run: `printf '%s|' "$1" "$2" > positional; printf '%s|' {args} > templated`, Enabled: true, Timeout: 5 * time.Second},
+	}
+
+	args := []string{"msg file; touch injected", "$(touch injected)"}
+	if _, err := RunHook("commit-msg", commands, Options{RepoRoot: dir, HookArgs: args}); err != nil {
+		t.Fatalf("RunHook() error = %v", err)
+	}
+
+	want := args[0] + "|" + args[1] + "|"
+	for _, name := range []string{"positional", "templated"} {
+		data, err := os.ReadFile(filepath.Join(dir, name))
+		if err != nil {
+			t.Fatal(err)
+		}
+		if string(data) != want {
+			t.Errorf("%s = %q, want %q", name, data, want)
+		}
+	}
+	if _, err := os.Stat(filepath.Join(dir, "injected")); err == nil {
+		t.Error("hook arguments were interpreted by the shell")
+	}
+}
+
+func TestSelectFiles(t *testing.T) {
+	goFiles, errs := config.NewFileFilter([]string{"*.go"}, []string{"vendor/**"}, nil)
+	if len(errs) > 0 {
+		t.Fatal(errs)
+	}
+	staged := []string{"main.go", "README.md", "vendor/x/y.go", "internal/a.go"}
+
+	tests := []struct {
+		name    string
+		command config.ResolvedHookCommand
+		files   []string
+		want    []string
+		wantRun bool
+	}{
+		{"no filter", config.ResolvedHookCommand{Run: "make"}, staged, staged, true},
+		{"no filter, no files", config.ResolvedHookCommand{Run: "make"}, nil, nil, true},
+		{"filter matches", config.ResolvedHookCommand{Run: "gofmt -l", Files: goFiles}, staged, []string{"main.go", "internal/a.go"}, true},
+		{"filter matches nothing", config.ResolvedHookCommand{Run: "gofmt -l", Files: goFiles}, []string{"README.md"}, nil, false},
+		{"placeholder, no files", config.ResolvedHookCommand{Run: "lint {staged_files}"}, nil, nil, false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, run := selectFiles(tt.command, tt.files)
+			if run != tt.wantRun {
+				t.Errorf("run = %v, want %v", run, tt.wantRun)
+			}
+			if strings.Join(got, ",") != strings.Join(tt.want, ",") {
+				t.Errorf("files = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestExpandScripts_SplitsLongFileLists(t *testing.T) {
+	orig := maxScriptLen
+	maxScriptLen = 64
+	defer func() { maxScriptLen = orig }()
+
+	var files []string
+	for i := 0; i < 20; i++ {
+		files = append(files, fmt.Sprintf("file%02d.go", i))
+	}
+
+	scripts := expandScripts("gofmt -l {staged_files}", nil, files, shellQuote)
+	if len(scripts) < 2 {
+		t.Fatalf("got %d scripts, want the file list split", len(scripts))
+	}
+
+	var seen []string
+	for _, script := range scripts {
+		if len(script) > maxScriptLen {
+			t.Errorf("script length %d exceeds %d: %s", len(script), maxScriptLen, script)
+		}
+		for _, word := range strings.Fields(strings.TrimPrefix(script, "gofmt -l ")) {
+			seen = append(seen, strings.Trim(word, "'"))
+		}
+	}
+	if strings.Join(seen, ",") != strings.Join(files, ",") {
+		t.Errorf("files across batches = %v, want %v", seen, files)
+	}
+}
+
+func TestRunHook_StagedFilesPlaceholder(t *testing.T) {
+	dir := t.TempDir()
+	filter, _ := config.NewFileFilter(nil, nil, []string{"go"})
+	commands := []config.ResolvedHookCommand{
+		{Run: "printf '%s\\n' {staged_files} > out", Enabled: true, Timeout: 5 * time.Second, Files: filter},
+	}
+
+	files := []string{"a b.go", "notes.txt", "pkg/c.go"}
+	if _, err := RunHook("pre-commit", commands, Options{RepoRoot: dir, Files: files}); err != nil {
+		t.Fatalf("RunHook() error = %v", err)
+	}
+
+	data, err := os.ReadFile(filepath.Join(dir, "out"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := "a b.go\npkg/c.go\n"; string(data) != want {
+		t.Errorf("out = %q, want %q", data, want)
+	}
+}
+
+func TestRunHook_StageFixedRestagesModifiedFiles(t *testing.T) {
+	dir := t.TempDir()
+	for _, args := range [][]string{{"init", "-q"}, {"config", "user.email", "test@example.com"}, {"config", "user.name", "test"}} {
+		runGit(t, dir, args...)
+	}
+	for _, name := range []string{"a.go", "b.go"} {
+		if err := os.WriteFile(filepath.Join(dir, name), []byte("package x\n"), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	runGit(t, dir, "add", "a.go", "b.go")
+
+	commands := []config.ResolvedHookCommand{
+		{Run: "echo '// fixed' >> a.go", Enabled: true, StageFixed: true, Timeout: 5 * time.Second},
+	}
+	summary, err := RunHook("pre-commit", commands, Options{RepoRoot: dir, Files: []string{"a.go", "b.go"}, Staged: true})
+	if err != nil {
+		t.Fatalf("RunHook() error = %v", err)
+	}
+
+	if got := summary.Results[0].Restaged; len(got) != 1 || got[0] != "a.go" {
+		t.Errorf("Restaged = %v, want [a.go]", got)
+	}
+	if unstaged := runGit(t, dir, "diff", "--name-only"); unstaged != "" {
+		t.Errorf("unstaged changes after re-staging: %q", unstaged)
+	}
+	if !strings.Contains(summary.Format(), "re-staged: a.go") {
+		t.Errorf("Format() should list re-staged files, got:\n%s", summary.Format())
+	}
+}
+
+func TestRunHook_StageFixedSkipsPartiallyStagedFiles(t *testing.T) {
+	dir := t.TempDir()
+	for _, args := range [][]string{{"init", "-q"}, {"config", "user.email", "test@example.com"}, {"config", "user.name", "test"}} {
+		runGit(t, dir, args...)
+	}
+	path := filepath.Join(dir, "a.go")
+	os.WriteFile(path, []byte("package x\n"), 0644)
+	runGit(t, dir, "add", "a.go")
+	os.WriteFile(path, []byte("package x\n// SECRET_WIP\n"), 0644)
+
+	commands := []config.ResolvedHookCommand{
+		{Run: "echo '// fixed' >> a.go", Enabled: true, StageFixed: true, Timeout: 5 * time.Second},
+	}
+	summary, err := RunHook("pre-commit", commands, Options{RepoRoot: dir, Files: []string{"a.go"}, Staged: true})
+	if err != nil {
+		t.Fatalf("RunHook() error = %v", err)
+	}
+
+	if got := summary.Results[0].Restaged; len(got) != 0 {
+		t.Errorf("Restaged = %v, want none", got)
+	}
+	if got := summary.Results[0].Unstaged; len(got) != 1 || got[0] != "a.go" {
+		t.Errorf("Unstaged = %v, want [a.go]", got)
+	}
+	if staged := runGit(t, dir, "show", ":a.go"); strings.Contains(staged, "SECRET_WIP") {
+		t.Errorf("unstaged line was staged: %q", staged)
+	}
+}
+
+func TestRunHook_StageFixedOnlyRestagesOwnFiles(t *testing.T) {
+	dir := t.TempDir()
+	for _, args := range [][]string{{"init", "-q"}, {"config", "user.email", "test@example.com"}, {"config", "user.name", "test"}} {
+		runGit(t, dir, args...)
+	}
+	for _, name := range []string{"a.go", "b.md"} {
+		os.WriteFile(filepath.Join(dir, name), []byte("x\n"), 0644)
+	}
+	runGit(t, dir, "add", "a.go", "b.md")
+
+	goFiles, _ := config.NewFileFilter(nil, nil, []string{"go"})
+	mdFiles, _ := config.NewFileFilter(nil, nil, []string{"md"})
+	commands := []config.ResolvedHookCommand{
+		{Run: "sleep 0.2; echo fixed >> a.go", Enabled: true, Parallel: true, StageFixed: true, Files: goFiles, Timeout: 5 * time.Second},
+		{Run: "echo edited >> b.md", Enabled: true, Parallel: true, Files: mdFiles, Timeout: 5 * time.Second},
+	}
+	summary, err := RunHook("pre-commit", commands, Options{RepoRoot: dir, Files: []string{"a.go", "b.md"}, Staged: true, MaxParallel: 2})
+	if err != nil {
+		t.Fatalf("RunHook() error = %v", err)
+	}
+
+	if got := summary.Results[0].Restaged; len(got) != 1 || got[0] != "a.go" {
+		t.Errorf("Restaged = %v, want [a.go]", got)
+	}
+	if unstaged := runGit(t, dir, "diff", "--name-only"); unstaged != "b.md" {
+		t.Errorf("unstaged files = %q, want b.md", unstaged)
+	}
+}
+
+func TestRunHook_StageFixedWithStashedOverlappingChanges(t *testing.T) {
+	dir := t.TempDir()
+	for _, args := range [][]string{{"init", "-q"}, {"config", "user.email", "test@example.com"}, {"config", "user.name", "test"}} {
+		runGit(t, dir, args...)
+	}
+	path := filepath.Join(dir, "a.go")
+	os.WriteFile(path, []byte("x  =  1\n"), 0644)
+	runGit(t, dir, "add", "a.go")
+	runGit(t, dir, "commit", "-q", "-m", "init")
+	os.WriteFile(path, []byte("x  =  2\n"), 0644)
+	runGit(t, dir, "add", "a.go")
+	os.WriteFile(path, []byte("x  =  3 // wip\n"), 0644)
+
+	stash, err := git.StashUnstaged(dir)
+	if err != nil {
+		t.Fatalf("StashUnstaged() error = %v", err)
+	}
+	// The formatter rewrites the line the unstaged change also touches
+	commands := []config.ResolvedHookCommand{
+		{Run: "sed -i.bak 's/  =  / = /' a.go && rm a.go.bak", Enabled: true, StageFixed: true, Timeout: 5 * time.Second},
+	}
+	if _, err := RunHook("pre-commit", commands, Options{RepoRoot: dir, Files: []string{"a.go"}, Staged: true}); err != nil {
+		t.Fatalf("RunHook() error = %v", err)
+	}
+	if err := stash.Restore(); err != nil {
+		t.Fatalf("Restore() error = %v", err)
+	}
+
+	if !stash.Conflicted {
+		t.Error("Conflicted = false, want true")
+	}
+	if got := runGit(t, dir, "show", ":a.go"); got != "x = 2" {
+		t.Errorf("staged a.go = %q, want the formatted staged content", got)
+	}
+	if data, _ := os.ReadFile(path); string(data) != "x  =  3 // wip\n" {
+		t.Errorf("a.go = %q, want the unstaged content", data)
+	}
+}
+
+func TestSummary_Format(t *testing.T) {
+	summary := &Summary{
+		HookName: "pre-commit",
+		Results: []Result{
+			{Command: config.ResolvedHookCommand{Run: "npm run lint"}, Status: StatusPassed, Elapsed: 1200 * time.Millisecond},
+			{Command: config.ResolvedHookCommand{Run: "npm run test"}, Status: StatusFailed},
+			{Command: config.ResolvedHookCommand{Run: "gofmt -l"}, Status: StatusSkipped, Reason: "no matching files"},
+		},
+	}
+
+	got := summary.Format()
+	checks := []string{
+		"pre-commit: 1 passed, 1 failed, 1 skipped",
+		"npm run lint                             passed (1.2s)",
+		"npm run test                             failed",
+		"gofmt -l                                 skipped (no matching files)",
+	}
+	for _, check := range checks {
+		if !strings.Contains(got, check) {
+			t.Errorf("Format() missing %q, got:\n%s", check, got)
+		}
+	}
+}
+
+// runGit runs git in dir and returns its trimmed output.
+func runGit(t *testing.T, dir string, args ...string) string {
+	t.Helper()
+	cmd := exec.Command("git", args...)
+	cmd.Dir = dir
+	output, err := cmd.CombinedOutput()
+	if err != nil {
+		t.Fatalf("git %v: %v\n%s", args, err, output)
+	}
+	return strings.TrimSpace(string(output))
+}
+
+func TestSelectJobs_ChangedPathsOnPrePush(t *testing.T) {
+	dir := t.TempDir()
+	for _, args := range [][]string{{"init", "-q"}, {"config", "user.email", "test@example.com"}, {"config", "user.name", "test"}} {
+		runGit(t, dir, args...)
+	}
+	os.WriteFile(filepath.Join(dir, "README.md"), []byte("x\n"), 0644)
+	runGit(t, dir, "add", ".")
+	runGit(t, dir, "commit", "-q", "-m", "base")
+	base := runGit(t, dir, "rev-parse", "HEAD")
+	os.WriteFile(filepath.Join(dir, "main.go"), []byte("package main\n"), 0644)
+	runGit(t, dir, "add", ".")
+	runGit(t, dir, "commit", "-q", "-m", "go")
+	head := runGit(t, dir, "rev-parse", "HEAD")
+
+	goFiles, _ := config.NewFileFilter([]string{"*.go"}, nil, nil)
+	mdFiles, _ := config.NewFileFilter([]string{"*.md"}, nil, nil)
+	commands := []config.ResolvedHookCommand{
+		{Run: "go test", Enabled: true, When: &config.Condition{ChangedPaths: goFiles}},
+		{Run: "mdlint", Enabled: true, When: &config.Condition{ChangedPaths: mdFiles}},
+	}
+	stdin := fmt.Sprintf("refs/heads/main %s refs/heads/main %s\n", head, base)
+	results := make([]Result, len(commands))
+	jobs := selectJobs("pre-push", commands, Options{RepoRoot: dir, Stdin: []byte(stdin)}, results)
+
+	if len(jobs) != 1 || jobs[0].command.Run != "go test" {
+		t.Errorf("jobs = %+v, want only go test", jobs)
+	}
+	if results[1].Reason != "when: no changed paths match" {
+		t.Errorf("mdlint reason = %q", results[1].Reason)
+	}
+
+	// A new branch sends every commit not on a remote
+	stdin = fmt.Sprintf("refs/heads/main %s refs/heads/main %s\n", head, strings.Repeat("0", 40))
+	jobs = selectJobs("pre-push", commands, Options{RepoRoot: dir, Stdin: []byte(stdin)}, make([]Result, len(commands)))
+	if len(jobs) != 2 {
+		t.Errorf("got %d jobs for a new branch, want 2", len(jobs))
+	}
+}
+
+func TestSelectJobs_FileFiltersUseTheRunsFileSet(t *testing.T) {
+	dir := t.TempDir()
+	for _, args := range [][]string{{"init", "-q"}, {"config", "user.email", "test@example.com"}, {"config", "user.name", "test"}} {
+		runGit(t, dir, args...)
+	}
+	os.WriteFile(filepath.Join(dir, "README.md"), []byte("x\n"), 0644)
+	runGit(t, dir, "add", ".")
+	runGit(t, dir, "commit", "-q", "-m", "base")
+	base := runGit(t, dir, "rev-parse", "HEAD")
+	os.WriteFile(filepath.Join(dir, "main.go"), []byte("package main\n"), 0644)
+	runGit(t, dir, "add", ".")
+	runGit(t, dir, "commit", "-q", "-m", "go")
+	head := runGit(t, dir, "rev-parse", "HEAD")
+
+	goFiles, _ := config.NewFileFilter([]string{"*.go"}, nil, nil)
+	mdFiles, _ := config.NewFileFilter([]string{"*.md"}, nil, nil)
+	commands := []config.ResolvedHookCommand{
+		{Run: "gofmt -l {staged_files}", Enabled: true, Files: goFiles},
+		{Run: "mdlint", Enabled: true, Files: mdFiles},
+	}
+
+	// pre-push filters the files the pushed commits change
+	stdin := fmt.Sprintf("refs/heads/main %s refs/heads/main %s\n", head, base)
+	results := make([]Result, len(commands))
+	jobs := selectJobs("pre-push", commands, Options{RepoRoot: dir, Stdin: []byte(stdin)}, results)
+	if len(jobs) != 1 || jobs[0].command.Run != "gofmt -l {staged_files}" || strings.Join(jobs[0].files, " ") != "main.go" {
+		t.Errorf("jobs = %+v, want gofmt on main.go", jobs)
+	}
+	if results[1].Reason != "no matching files" {
+		t.Errorf("mdlint reason = %q", results[1].Reason)
+	}
+
+	// Files given to ghm run are used on any hook
+	jobs = selectJobs("pre-push", commands, Options{RepoRoot: dir, Stdin: []byte(stdin), Files: []string{"README.md"}}, make([]Result, len(commands)))
+	if len(jobs) != 1 || jobs[0].command.Run != "mdlint" {
+		t.Errorf("jobs with Files = %+v, want only mdlint", jobs)
+	}
+	jobs = selectJobs("commit-msg", commands, Options{RepoRoot: dir, Files: []string{"main.go", "README.md"}}, make([]Result, len(commands)))
+	if len(jobs) != 2 {
+		t.Errorf("got %d jobs on commit-msg with Files, want 2", len(jobs))
+	}
+
+	// Without a file set, filtered commands are skipped
+	if jobs := selectJobs("commit-msg", commands, Options{RepoRoot: dir}, make([]Result, len(commands))); len(jobs) != 0 {
+		t.Errorf("got %d jobs on commit-msg without files, want 0", len(jobs))
+	}
+}
+
+func TestCheckCondition_Except(t *testing.T) {
+	t.Setenv("GHM_TEST_CI", "true")
+	ci := &config.Condition{Env: []config.EnvMatch{{Name: "GHM_TEST_CI"}}}
+	main := &config.Condition{Branches: []string{"main"}}
+
+	tests := []struct {
+		name   string
+		when   *config.Condition
+		except *config.Condition
+		branch string
+		want   bool
+		reason string
+	}{
+		{"except holds", nil, main, "main", false, "except: branch main matches"},
+		{"except does not hold", nil, main, "feature/x", true, "except: branch feature/x does not match main"},
+		{"when fails first", main, ci, "feature/x", false, "when: branch feature/x does not match main"},
+		{"when holds, except holds", main, ci, "main", false, "except: env GHM_TEST_CI"},
+		{"both reported", ci, main, "feature/x", true, "when: env GHM_TEST_CI; except: branch"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			command := config.ResolvedHookCommand{Run: "make", When: tt.when, Except: tt.except}
+			got, reason := checkCondition("pre-commit", command, Options{Branch: tt.branch})
+			if got != tt.want || !strings.Contains(reason, tt.reason) {
+				t.Errorf("checkCondition() = %v (%s), want %v (%s)", got, reason, tt.want, tt.reason)
+			}
+		})
+	}
+}
+
+func TestSkipReason(t *testing.T) {
+	lint := config.ResolvedHookCommand{ID: "lint", Run: "npm run lint"}
+	test := config.ResolvedHookCommand{Run: "npm test"}
+
+	opts := Options{
+		Skip: []SkipRule{{Selector: "lint", Reason: "GHM_SKIP"}},
+		Only: []string{"lint", "npm test"},
+	}
+	if reason, skip := skipReason(lint, opts); !skip || reason != "GHM_SKIP" {
+		t.Errorf("skipReason(lint) = %q, %v; want GHM_SKIP, true", reason, skip)
+	}
+	if _, skip := skipReason(test, opts); skip {
+		t.Error("skipReason(test) should not skip a command selected by --only")
+	}
+
+	opts = Options{Only: []string{"lint"}}
+	if reason, skip := skipReason(test, opts); !skip || reason != "--only" {
+		t.Errorf("skipReason(test) = %q, %v; want --only, true", reason, skip)
+	}
+}
+
+func TestRunHook_SkippedCommandsInSummary(t *testing.T) {
+	dir := t.TempDir()
+	commands := []config.ResolvedHookCommand{
+		{ID: "lint", Run: "touch lint-ran", Enabled: true, Timeout: 5 * time.Second},
+		{Run: "touch test-ran", Enabled: true, Timeout: 5 * time.Second},
+	}
+
+	opts := Options{RepoRoot: dir, Skip: []SkipRule{{Selector: "lint", Reason: "GHM_SKIP"}}}
+	summary, err := RunHook("pre-commit", commands, opts)
+	if err != nil {
+		t.Fatalf("RunHook() error = %v", err)
+	}
+
+	if _, err := os.Stat(filepath.Join(dir, "lint-ran")); err == nil {
+		t.Error("skipped command should not run")
+	}
+	if _, err := os.Stat(filepath.Join(dir, "test-ran")); err != nil {
+		t.Error("other command should still run")
+	}
+	if got := summary.Format(); !strings.Contains(got, "skipped (GHM_SKIP)") {
+		t.Errorf("Format() missing 'skipped (GHM_SKIP)', got:\n%s", got)
+	}
+}
+
+func TestCheckCondition(t *testing.T) {
+	t.Setenv("GHM_TEST_CI", "true")
+	goFiles, _ := config.NewFileFilter([]string{"*.go"}, nil, nil)
+
+	tests := []struct {
+		name     string
+		hookName string
+		cond     *config.Condition
+		opts     Options
+		want     bool
+		reason   string
+	}{
+		{"no condition", "pre-commit", nil, Options{}, true, ""},
+		{"branch matches", "pre-commit", &config.Condition{Branches: []string{"release/*"}}, Options{Branch: "release/1.0"}, true, "branch release/1.0 matches"},
+		{"branch differs", "pre-commit", &config.Condition{Branches: []string{"main"}}, Options{Branch: "feature/x"}, false, "does not match main"},
+		{"detached", "pre-commit", &config.Condition{Branches: []string{"main"}}, Options{}, false, "not on a branch"},
+		{"pushed branch", "pre-push", &config.Condition{Branches: []string{"main"}}, Options{Branch: "feature/x", Stdin: []byte("refs/heads/x 111 refs/heads/main 222\n")}, true, "branch main matches"},
+		{"current branch not pushed", "pre-push", &config.Condition{Branches: []string{"main"}}, Options{Branch: "main", Stdin: []byte("refs/heads/x 111 refs/heads/x 222\n")}, false, "branch x does not match main"},
+		{"nothing pushed", "pre-push", &config.Condition{Branches: []string{"main"}}, Options{Branch: "main"}, false, "no branch is being pushed"},
+		{"env set", "pre-commit", &config.Condition{Env: []config.EnvMatch{{Name: "GHM_TEST_CI"}}}, Options{}, true, "env GHM_TEST_CI"},
+		{"env value differs", "pre-commit", &config.Condition{Env: []config.EnvMatch{{Name: "GHM_TEST_CI", Value: "false", HasValue: true}}}, Options{}, false, `is not "false"`},
+		{"env unset", "pre-commit", &config.Condition{Env: []config.EnvMatch{{Name: "GHM_TEST_UNSET"}}}, Options{}, false, "GHM_TEST_UNSET is not set"},
+		{"changed paths", "pre-commit", &config.Condition{ChangedPaths: goFiles}, Options{Files: []string{"README.md"}}, false, "no changed paths match"},
+		{"shell passes", "pre-commit", &config.Condition{Shell: "true"}, Options{RepoRoot: t.TempDir()}, true, "shell condition passed"},
+		{"shell fails", "pre-commit", &config.Condition{Shell: "exit 3"}, Options{RepoRoot: t.TempDir()}, false, "status 3"},
+		{"os matches", "pre-commit", &config.Condition{OS: []string{"plan9", runtime.GOOS}}, Options{}, true, "os " + runtime.GOOS},
+		{"os differs", "pre-commit", &config.Condition{OS: []string{"plan9"}}, Options{}, false, "os " + runtime.GOOS + " is not plan9"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			command := config.ResolvedHookCommand{Run: "make", When: tt.cond, Timeout: 5 * time.Second}
+			got, reason := checkCondition(tt.hookName, command, tt.opts)
+			if got != tt.want {
+				t.Errorf("checkCondition() = %v (%s), want %v", got, reason, tt.want)
+			}
+			if !strings.Contains(reason, tt.reason) {
+				t.Errorf("reason = %q, want it to contain %q", reason, tt.reason)
+			}
+		})
+	}
+}
@@ -0,0 +1,37 @@
+package runner
+
+import (
+	"githookd/internal/config"
+)
+
+// commandArgv returns the argv that runs script, an expanded run string,
+// with the command's shell. POSIX shells receive the hook arguments as
+// positional parameters, with $0 set to "ghm"; custom interpreters receive
+// them as trailing arguments; pwsh gets them only from GHM_HOOK_ARGS.
+// Script commands run the script file with its interpreter, or sh.
+func commandArgv(command config.ResolvedHookCommand, script string, opts Options) []string {
+	var argv []string
+	switch {
+	case command.Script != "":
+		interpreter := command.Interpreter
+		if len(interpreter) == 0 {
+			interpreter = []string{"sh"}
+		}
+		argv = append(argv, interpreter...)
+		argv = append(argv, command.Script)
+		return append(argv, opts.HookArgs...)
+	case len(command.Shell.Argv) == 0:
+		argv = append(argv, config.DefaultShell.Argv...)
+	default:
+		argv = append(argv, command.Shell.Argv...)
+	}
+
+	argv = append(argv, script)
+	switch {
+	case command.Shell.POSIX() || len(command.Shell.Argv) == 0:
+		argv = append(argv, "ghm")
+	case command.Shell.Name != "":
+		return argv
+	}
+	return append(argv, opts.HookArgs...)
+}
@@ -0,0 +1,106 @@
+package runner
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"io"
+	"log/slog"
+	"os"
+	"path"
+	"path/filepath"
+	"sort"
+
+	"githookd/internal/git"
+)
+
+// snapshotFiles records a content hash for each file so that changes made
+// by a command can be detected afterwards. Missing files hash to "".
+func snapshotFiles(repoRoot string, files []string) map[string]string {
+	snapshot := make(map[string]string, len(files))
+	for _, name := range files {
+		snapshot[name] = hashFile(filepath.Join(repoRoot, name))
+	}
+	return snapshot
+}
+
+// usesStageFixed reports whether any job re-stages the files it modifies.
+func usesStageFixed(jobs []job) bool {
+	for _, j := range jobs {
+		if j.command.StageFixed {
+			return true
+		}
+	}
+	return false
+}
+
+// unstagedFiles returns the set of files with unstaged changes, or nil if
+// they cannot be listed.
+func unstagedFiles(repoRoot string) map[string]bool {
+	files, err := git.GetUnstagedFiles(repoRoot)
+	if err != nil {
+		slog.Warn("Cannot list unstaged changes; stage_fixed will not re-stage files", "error", err)
+		return nil
+	}
+	set := make(map[string]bool, len(files))
+	for _, name := range files {
+		set[name] = true
+	}
+	return set
+}
+
+// splitPartial separates the files that had unstaged changes from the
+// rest. A nil set means unknown, so every file is treated as partial.
+func splitPartial(files []string, unstaged map[string]bool) (clean, partial []string) {
+	for _, name := range files {
+		if unstaged == nil || unstaged[name] {
+			partial = append(partial, name)
+		} else {
+			clean = append(clean, name)
+		}
+	}
+	return clean, partial
+}
+
+// rootFiles returns a job's files relative to the repository root rather
+// than to the command's dir.
+func rootFiles(j job) []string {
+	if j.command.Dir == "" {
+		return j.files
+	}
+	files := make([]string, len(j.files))
+	for i, name := range j.files {
+		files[i] = path.Join(j.command.Dir, name)
+	}
+	return files
+}
+
+// changedFiles returns the files from a snapshot whose content differs
+// from the snapshot, in sorted order. Files that were deleted are not
+// reported since there is nothing left to stage.
+func changedFiles(repoRoot string, before map[string]string) []string {
+	var changed []string
+	for name, hash := range before {
+		now := hashFile(filepath.Join(repoRoot, name))
+		if now != "" && now != hash {
+			changed = append(changed, name)
+		}
+	}
+	sort.Strings(changed)
+	return changed
+}
+
+// hashFile returns the hex SHA-256 of a file's content, or "" if it cannot
+// be read.
+func hashFile(path string) string {
+	f, err := os.Open(path)
+	if err != nil {
+		return ""
+	}
+	defer f.Close()
+
+	h := sha256.New()
+	if _, err := io.Copy(h, f); err != nil {
+		return ""
+	}
+	return hex.EncodeToString(h.Sum(nil))
+}
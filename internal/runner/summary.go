@@ -0,0 +1,125 @@
+package runner
+
+import (
+	"fmt"
+	"os"
+	"strings"
+	"time"
+
+	"githookd/internal/config"
+)
+
+// Status is the outcome of a single command in a hook run.
+type Status int
+
+const (
+	StatusPassed Status = iota
+	StatusFailed
+	StatusSkipped
+	StatusWarned      // failed, but the command's on_failure is warn
+	StatusInterrupted // stopped by a signal forwarded from ghm
+)
+
+// String returns the lowercase name of the status.
+func (s Status) String() string {
+	switch s {
+	case StatusPassed:
+		return "passed"
+	case StatusFailed:
+		return "failed"
+	case StatusSkipped:
+		return "skipped"
+	case StatusWarned:
+		return "warned"
+	case StatusInterrupted:
+		return "interrupted"
+	default:
+		return "unknown"
+	}
+}
+
+// Result records what happened to a single command.
+type Result struct {
+	Command  config.ResolvedHookCommand
+	Status   Status
+	Reason   string     // why the command was skipped
+	Err      *HookError // set when Status is StatusFailed or StatusWarned
+	Restaged []string   // files added back to the index after the command ran
+	Unstaged []string   // modified files left unstaged as they had unstaged changes
+	Attempts int        // number of times the command was run
+	Elapsed  time.Duration
+}
+
+// Summary collects the results of a hook run in configuration order.
+type Summary struct {
+	HookName    string
+	Results     []Result
+	Interrupted os.Signal // set when a signal stopped the run
+}
+
+// Format returns a short, human-readable overview of the run with one line
+// per command.
+func (s *Summary) Format() string {
+	var b strings.Builder
+	counts := make(map[Status]int)
+	for _, r := range s.Results {
+		counts[r.Status]++
+	}
+
+	b.WriteString("-----------------------------------------------------------\n")
+	b.WriteString(fmt.Sprintf(" %s: %d passed, %d failed, ", s.HookName, counts[StatusPassed], counts[StatusFailed]))
+	if counts[StatusWarned] > 0 {
+		b.WriteString(fmt.Sprintf("%d warned, ", counts[StatusWarned]))
+	}
+	if counts[StatusInterrupted] > 0 {
+		b.WriteString(fmt.Sprintf("%d interrupted, ", counts[StatusInterrupted]))
+	}
+	b.WriteString(fmt.Sprintf("%d skipped\n", counts[StatusSkipped]))
+	if s.Interrupted != nil {
+		b.WriteString(fmt.Sprintf(" interrupted by %s; remaining commands were not run\n", s.Interrupted))
+	}
+	b.WriteString("-----------------------------------------------------------\n")
+
+	for _, r := range s.Results {
+		status := r.Status.String()
+		if r.Status == StatusSkipped {
+			status += fmt.Sprintf(" (%s)", r.Reason)
+		} else {
+			detail := r.Elapsed.Round(time.Millisecond).String()
+			if r.Attempts > 1 {
+				detail += fmt.Sprintf(", %d attempts", r.Attempts)
+			}
+			status += fmt.Sprintf(" (%s)", detail)
+		}
+		b.WriteString(fmt.Sprintf("  %-40s %s\n", r.Command.Name(), status))
+		if len(r.Restaged) > 0 {
+			b.WriteString(fmt.Sprintf("  %-40s re-staged: %s\n", "", strings.Join(r.Restaged, ", ")))
+		}
+		if len(r.Unstaged) > 0 {
+			b.WriteString(fmt.Sprintf("  %-40s not re-staged (had unstaged changes): %s\n", "", strings.Join(r.Unstaged, ", ")))
+		}
+	}
+
+	return b.String()
+}
+
+// Err returns the error for the run as a whole: nil if no command failed,
+// the failure itself if exactly one did, or a HookErrors listing every
+// failure otherwise. Warnings never make the run fail.
+func (s *Summary) Err() error {
+	var errs HookErrors
+	for _, r := range s.Results {
+		if r.Status == StatusFailed {
+			errs = append(errs, r.Err)
+		}
+	}
+
+	switch len(errs) {
+	case 0:
+		return nil
+	case 1:
+		return errs[0]
+	default:
+		return errs
+	}
+}
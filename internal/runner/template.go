@@ -0,0 +1,149 @@
+package runner
+
+import (
+	"runtime"
+	"strings"
+
+	"githookd/internal/config"
+)
+
+// expandTemplate replaces {name} placeholders in a run string with the
+// values from vars, each quoted with quote. Multi-valued placeholders
+// expand to one quoted word per value. Placeholders preceded by '$' are
+// left alone so that shell parameter expansions such as ${args} keep their
+// meaning, and unknown placeholders are kept verbatim.
+func expandTemplate(script string, vars map[string][]string, quote func(string) string) string {
+	var b strings.Builder
+
+	for i := 0; i < len(script); {
+		if script[i] != '{' || (i > 0 && script[i-1] == '$') {
+			b.WriteByte(script[i])
+			i++
+			continue
+		}
+
+		end := strings.IndexByte(script[i:], '}')
+		if end < 0 {
+			b.WriteString(script[i:])
+			break
+		}
+
+		values, ok := vars[script[i+1:i+end]]
+		if !ok {
+			b.WriteByte(script[i])
+			i++
+			continue
+		}
+
+		quoted := make([]string, len(values))
+		for j, v := range values {
+			quoted[j] = quote(v)
+		}
+		b.WriteString(strings.Join(quoted, " "))
+		i += end + 1
+	}
+
+	return b.String()
+}
+
+// shellQuote quotes s so that a POSIX shell reads it back as a single word
+// with no expansion.
+func shellQuote(s string) string {
+	if s == "" {
+		return "''"
+	}
+	return "'" + strings.ReplaceAll(s, "'", `'\''`) + "'"
+}
+
+// pwshQuote quotes s so that PowerShell reads it back as a single word
+// with no expansion. PowerShell also ends single-quoted strings at the
+// typographic single quotes, so those are doubled too.
+func pwshQuote(s string) string {
+	var b strings.Builder
+	b.WriteByte('\'')
+	for _, r := range s {
+		if strings.ContainsRune("'\u2018\u2019\u201a\u201b", r) {
+			b.WriteRune(r)
+		}
+		b.WriteRune(r)
+	}
+	b.WriteByte('\'')
+	return b.String()
+}
+
+// quoteFunc returns the function that quotes words for shell. Resolve
+// rejects placeholders for shells whose quoting is unknown, so those get
+// POSIX quoting.
+func quoteFunc(shell config.Shell) func(string) string {
+	if shell.Quoting() == config.QuotePowerShell {
+		return pwshQuote
+	}
+	return shellQuote
+}
+
+// maxScriptLen bounds the length of an expanded run string. It stays below
+// Linux's 128 KiB limit on a single argument, and below the 32 KiB command
+// line limit on Windows.
+var maxScriptLen = defaultMaxScriptLen()
+
+func defaultMaxScriptLen() int {
+	if runtime.GOOS == "windows" {
+		return 30 * 1024
+	}
+	return 100 * 1024
+}
+
+// expandScripts expands a run string with the given variables and files,
+// quoted with quote. When the run string references {staged_files} and the
+// expansion would exceed maxScriptLen, the files are split into batches
+// and one script is returned per batch.
+func expandScripts(run string, vars map[string][]string, files []string, quote func(string) string) []string {
+	n := countPlaceholder(run, "staged_files")
+	if n == 0 {
+		return []string{expandTemplate(run, vars, quote)}
+	}
+
+	expand := func(batch []string) string {
+		batchVars := make(map[string][]string, len(vars)+1)
+		for k, v := range vars {
+			batchVars[k] = v
+		}
+		batchVars["staged_files"] = batch
+		return expandTemplate(run, batchVars, quote)
+	}
+
+	baseLen := len(expand(nil))
+	var scripts []string
+	var batch []string
+	size := baseLen
+
+	for _, f := range files {
+		fileLen := n * (len(quote(f)) + 1)
+		if len(batch) > 0 && size+fileLen > maxScriptLen {
+			scripts = append(scripts, expand(batch))
+			batch = nil
+			size = baseLen
+		}
+		batch = append(batch, f)
+		size += fileLen
+	}
+	return append(scripts, expand(batch))
+}
+
+// countPlaceholder returns how many times {name} appears in script, not
+// counting shell expansions of the form ${name}.
+func countPlaceholder(script, name string) int {
+	token := "{" + name + "}"
+	count := 0
+	for i := strings.Index(script, token); i >= 0; {
+		if i == 0 || script[i-1] != '$' {
+			count++
+		}
+		next := strings.Index(script[i+len(token):], token)
+		if next < 0 {
+			break
+		}
+		i += len(token) + next
+	}
+	return count
+}